@@ -0,0 +1,58 @@
+package zaphttp
+
+import (
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ecsLabels represents custom key value pairs formatted for elastic common schema logging.
+// See: https://www.elastic.co/guide/en/ecs/current/ecs-base.html#field-labels
+type ecsLabels map[string]string
+
+func (l ecsLabels) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range sortedKeys(l) {
+		enc.AddString(k, l[k])
+	}
+	return nil
+}
+
+// baggageLabelsField returns all OpenTelemetry baggage members as ECS labels. Returns false if there is no baggage.
+// When multiple members have the same label key after sanitizing, the member with the lowest baggage key is kept.
+func baggageLabelsField(b baggage.Baggage) (zap.Field, bool) {
+	members := b.Members()
+	if len(members) == 0 {
+		return zap.Skip(), false
+	}
+
+	// The order of baggage members is not stable, sort them so the same member wins every time.
+	slices.SortFunc(members, func(a, b baggage.Member) int {
+		return strings.Compare(a.Key(), b.Key())
+	})
+
+	labels := make(ecsLabels, len(members))
+	for _, m := range members {
+		key := sanitizeLabelKey(m.Key())
+		if _, exists := labels[key]; exists {
+			continue
+		}
+		labels[key] = m.Value()
+	}
+	return zap.Object("labels", labels), true
+}
+
+// sanitizeLabelKey converts a key to a valid ECS label key. Label keys can not contain dots, only letters, digits and
+// underscores are kept, all other characters are replaced with an underscore.
+func sanitizeLabelKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,100 @@
+package zaphttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithBaggageLabels(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithBaggageLabels(),
+	)
+
+	tenant, err := baggage.NewMember("tenant.id", "acme")
+	require.NoError(t, err)
+	plan, err := baggage.NewMember("plan", "enterprise")
+	require.NoError(t, err)
+	b, err := baggage.New(tenant, plan)
+	require.NoError(t, err)
+
+	ctx := baggage.ContextWithBaggage(context.Background(), b)
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	require.Len(t, lines, 1)
+
+	labels, ok := lines[0].ContextMap()["labels"].(map[string]interface{})
+	require.True(t, ok, "labels field should be a map")
+	assert.Equal(t, map[string]interface{}{
+		"tenant_id": "acme",
+		"plan":      "enterprise",
+	}, labels)
+}
+
+func TestWithBaggageLabelsKeyClash(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithBaggageLabels(),
+	)
+
+	// Both keys become "tenant_id", the lowest baggage key is kept.
+	dotted, err := baggage.NewMember("tenant.id", "dotted")
+	require.NoError(t, err)
+	dashed, err := baggage.NewMember("tenant-id", "dashed")
+	require.NoError(t, err)
+	zone, err := baggage.NewMember("zone", "eu")
+	require.NoError(t, err)
+	plan, err := baggage.NewMember("plan", "enterprise")
+	require.NoError(t, err)
+	b, err := baggage.New(dotted, dashed, zone, plan)
+	require.NoError(t, err)
+
+	ctx := baggage.ContextWithBaggage(context.Background(), b)
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logs.All()
+	require.Len(t, lines, 1)
+
+	var labels zap.Field
+	for _, f := range lines[0].Context {
+		if f.Key == "labels" {
+			labels = f
+		}
+	}
+
+	// Labels are encoded sorted by key.
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+	buf, err := enc.EncodeEntry(zapcore.Entry{}, []zap.Field{labels})
+	require.NoError(t, err)
+	assert.Equal(t, `{"labels":{"plan":"enterprise","tenant_id":"dashed","zone":"eu"}}`+"\n", buf.String())
+}
@@ -0,0 +1,67 @@
+package zaphttp
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// bodyCapture copies the first bytes of the request body while the handler reads it. Nothing is read before the handler
+// runs, only the part of the body the handler consumed is captured. It is used for the body preview and request body
+// logging.
+type bodyCapture struct {
+	original io.ReadCloser
+	maxBytes int
+
+	mu        sync.Mutex
+	data      []byte
+	truncated bool
+}
+
+// captureRequestBody replaces the request body with a reader that captures up to maxBytes of the body. Returns nil if
+// the body is empty or its content type is not accepted by the matcher.
+func captureRequestBody(req *http.Request, maxBytes int, matches contentTypeMatcher) *bodyCapture {
+	if maxBytes <= 0 || req.Body == nil || req.Body == http.NoBody || !matches(req.Header.Get("Content-Type")) {
+		return nil
+	}
+
+	c := &bodyCapture{
+		original: req.Body,
+		maxBytes: maxBytes,
+	}
+	req.Body = c
+	return c
+}
+
+func (c *bodyCapture) Read(p []byte) (int, error) {
+	n, err := c.original.Read(p)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if remaining := c.maxBytes - len(c.data); n > remaining {
+		c.data = append(c.data, p[:remaining]...)
+		c.truncated = true
+	} else {
+		c.data = append(c.data, p[:n]...)
+	}
+	return n, err
+}
+
+func (c *bodyCapture) Close() error {
+	return c.original.Close()
+}
+
+// Body returns the captured part of the body and whether the body was longer than the maximum size. Returns nil if
+// the capture is nil or nothing was read.
+func (c *bodyCapture) Body() ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.data) == 0 {
+		return nil, false
+	}
+	return c.data, c.truncated
+}
@@ -0,0 +1,102 @@
+package zaphttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithRequestBodyLogging(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    map[string]interface{}
+	}{
+		{
+			name:        "Text body",
+			contentType: "application/json",
+			body:        `{"name":"gopher"}`,
+			expected: map[string]interface{}{
+				"bytes":   int64(17),
+				"content": `{"name":"gopher"}`,
+			},
+		},
+		{
+			name:        "Truncated body",
+			contentType: "text/plain",
+			body:        "abcdefghijklmnopqrstuvwxyz",
+			expected: map[string]interface{}{
+				"bytes":     int64(26),
+				"content":   "abcdefghijklmnopqrst",
+				"truncated": true,
+			},
+		},
+		{
+			name:        "Binary body",
+			contentType: "application/octet-stream",
+			body:        "\x00\x01\x02",
+			expected: map[string]interface{}{
+				"bytes":   int64(3),
+				"content": "AAEC",
+			},
+		},
+		{
+			name:        "Content type not configured",
+			contentType: "image/png",
+			body:        "\x89PNG",
+			expected: map[string]interface{}{
+				"bytes": int64(4),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithRequestBodyLogging(20, []string{"text/*", "application/json", "application/octet-stream"}),
+			)
+
+			var received []byte
+			handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var err error
+				received, err = io.ReadAll(req.Body)
+				assert.NoError(t, err)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// The handler must receive the full body.
+			assert.Equal(t, tt.body, string(received))
+
+			lines := logs.All()
+			require.Len(t, lines, 1)
+
+			httpMap, ok := lines[0].ContextMap()["http"].(map[string]interface{})
+			require.True(t, ok, "http field should be a map")
+			requestMap, ok := httpMap["request"].(map[string]interface{})
+			require.True(t, ok, "request field should be a map")
+			assert.Equal(t, tt.expected, requestMap["body"])
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package zaphttp
+
+import (
+	"mime"
+	"strings"
+)
+
+// contentTypeMatcher returns true if a body with the content type should be previewed.
+type contentTypeMatcher func(contentType string) bool
+
+// newContentTypeMatcher returns a matcher that accepts the listed media types, ignoring parameters like the charset.
+// A media type ending in "/*" accepts all subtypes, e.g. "text/*".
+func newContentTypeMatcher(mediaTypes []string) contentTypeMatcher {
+	return func(contentType string) bool {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return false
+		}
+
+		for _, t := range mediaTypes {
+			t = strings.ToLower(t)
+			if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isTextContentType returns true for content types that contain human-readable text.
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded", "application/x-ndjson",
+		"application/graphql", "application/javascript":
+		return true
+	default:
+		return false
+	}
+}
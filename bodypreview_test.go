@@ -0,0 +1,227 @@
+package zaphttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithBodyPreview(t *testing.T) {
+	t.Parallel()
+
+	body := `{"name":"a very long name that does not fit in the preview"}`
+
+	t.Run("Should log a truncated preview on errors and keep the body intact", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithBodyPreview(10, true),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		rec := httptest.NewRecorder()
+
+		var receivedBody string
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			data, err := io.ReadAll(req.Body)
+			assert.NoError(t, err)
+			receivedBody = string(data)
+			w.WriteHeader(http.StatusBadRequest)
+		})).ServeHTTP(rec, req)
+
+		assert.Equal(t, body, receivedBody)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, `{"name":"a`, lines[0].ContextMap()["request.body_preview"])
+		assert.Equal(t, true, lines[0].ContextMap()["request.body_preview_truncated"])
+	})
+
+	t.Run("Should not log a preview for successful requests when only logging on errors", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithBodyPreview(10, true),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.NotContains(t, lines[0].ContextMap(), "request.body_preview")
+	})
+
+	t.Run("Should log the full body when it fits in the preview", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithBodyPreview(200, false),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = io.Copy(io.Discard, req.Body)
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, body, lines[0].ContextMap()["request.body_preview"])
+		assert.Equal(t, false, lines[0].ContextMap()["request.body_preview_truncated"])
+	})
+
+	t.Run("Should skip binary content types", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithBodyPreview(10, false),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("\x00\x01\x02"))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.NotContains(t, lines[0].ContextMap(), "request.body_preview")
+	})
+
+	t.Run("Should only preview configured content types", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithBodyPreview(10, false),
+			zaphttp.WithBodyPreviewContentTypes("application/x-custom", "text/*"),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = io.Copy(io.Discard, req.Body)
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		for _, contentType := range []string{"application/x-custom", "text/csv; charset=utf-8", "application/json"} {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a,b,c"))
+			req.Header.Set("Content-Type", contentType)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		lines := logs.All()
+		assert.Len(t, lines, 3)
+		assert.Equal(t, "a,b,c", lines[0].ContextMap()["request.body_preview"])
+		assert.Equal(t, "a,b,c", lines[1].ContextMap()["request.body_preview"])
+		assert.NotContains(t, lines[2].ContextMap(), "request.body_preview")
+	})
+
+	t.Run("Should not wait for slow clients", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithBodyPreview(10, false),
+		)
+
+		// The client stalls until the handler was called, the handler must not wait for the body.
+		handlerCalled := make(chan struct{})
+		body := &blockingReader{unblock: handlerCalled, data: strings.NewReader("abcdef")}
+
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.Header.Set("Content-Type", "text/plain")
+		rec := httptest.NewRecorder()
+
+		var receivedBody string
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			close(handlerCalled)
+
+			data, err := io.ReadAll(req.Body)
+			assert.NoError(t, err)
+			receivedBody = string(data)
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		assert.Equal(t, "abcdef", receivedBody)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "abcdef", lines[0].ContextMap()["request.body_preview"])
+		// Nothing was cut off, the whole body fits in the preview.
+		assert.Equal(t, false, lines[0].ContextMap()["request.body_preview_truncated"])
+	})
+
+	t.Run("Should not log a preview if the handler did not read the body", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithBodyPreview(10, false),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.NotContains(t, lines[0].ContextMap(), "request.body_preview")
+	})
+}
+
+// blockingReader blocks until unblock is closed, then reads from data.
+type blockingReader struct {
+	unblock <-chan struct{}
+	data    io.Reader
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	<-r.unblock
+	return r.data.Read(p)
+}
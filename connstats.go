@@ -0,0 +1,53 @@
+package zaphttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const connectionStatsContextKey contextKey = "connection_stats"
+
+// connectionStats holds statistics about a single client connection.
+type connectionStats struct {
+	accepted time.Time
+	requests atomic.Int64
+}
+
+// InstallConnectionStats configures srv to keep statistics about every client connection. Use WithConnectionStats to
+// log these statistics. The statistics are stored in the base context of the connection using srv.ConnContext, this
+// is the only server hook that can pass data to the requests served on a connection. An existing ConnContext
+// function is kept and called first.
+//
+// Call InstallConnectionStats before the server is started:
+//
+//	srv := &http.Server{Handler: zaphttp.NewHandler(zaphttp.WithConnectionStats())(mux)}
+//	zaphttp.InstallConnectionStats(srv)
+//	srv.ListenAndServe()
+func InstallConnectionStats(srv *http.Server) {
+	next := srv.ConnContext
+	srv.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		if next != nil {
+			ctx = next(ctx, conn)
+		}
+		return context.WithValue(ctx, connectionStatsContextKey, &connectionStats{accepted: time.Now()})
+	}
+}
+
+// connectionStatsFields counts the request on its connection and returns the connection statistics. Returns nil if
+// InstallConnectionStats was not used for the server.
+func connectionStatsFields(req *http.Request, now time.Time) []zap.Field {
+	stats, ok := req.Context().Value(connectionStatsContextKey).(*connectionStats)
+	if !ok {
+		return nil
+	}
+
+	return []zap.Field{
+		zap.Duration("connection.age", now.Sub(stats.accepted)),
+		zap.Int64("connection.requests", stats.requests.Add(1)),
+	}
+}
@@ -0,0 +1,77 @@
+package zaphttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithConnectionStats(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should count the requests served on a connection", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithConnectionStats(),
+		)
+
+		srv := httptest.NewUnstartedServer(requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+		zaphttp.InstallConnectionStats(srv.Config)
+		srv.Start()
+		defer srv.Close()
+
+		// The client keeps the connection alive, so both requests are served on the same connection.
+		client := srv.Client()
+		for range 2 {
+			res, err := client.Get(srv.URL)
+			require.NoError(t, err)
+			_, _ = io.Copy(io.Discard, res.Body)
+			require.NoError(t, res.Body.Close())
+		}
+
+		lines := logs.All()
+		require.Len(t, lines, 2)
+		assert.Equal(t, int64(1), lines[0].ContextMap()["connection.requests"])
+		assert.Equal(t, int64(2), lines[1].ContextMap()["connection.requests"])
+		assert.IsType(t, time.Duration(0), lines[1].ContextMap()["connection.age"])
+	})
+
+	t.Run("Should not log stats when not installed on the server", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithConnectionStats(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.NotContains(t, lines[0].ContextMap(), "connection.requests")
+	})
+}
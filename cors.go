@@ -0,0 +1,25 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// corsPreflightFields returns fields describing a CORS preflight request. Returns nil if the request is not a
+// preflight request, see: https://fetch.spec.whatwg.org/#cors-preflight-request
+func corsPreflightFields(req *http.Request) []zap.Field {
+	method := req.Header.Get("Access-Control-Request-Method")
+	if req.Method != http.MethodOptions || method == "" {
+		return nil
+	}
+
+	fields := []zap.Field{
+		zap.Bool("cors_preflight", true),
+		zap.String("cors.request_method", method),
+	}
+	if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		fields = append(fields, zap.String("cors.request_headers", headers))
+	}
+	return fields
+}
@@ -2,28 +2,157 @@ package zaphttp
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"sync/atomic"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type contextKey string
 
 const (
-	loggerContextKey contextKey = "logger"
+	requestStateContextKey contextKey = "state"
 )
 
-func injectLoggerInContext(req *http.Request, l *zap.Logger) *http.Request {
-	ctx := context.WithValue(req.Context(), loggerContextKey, l)
+// requestState holds the per-request data that is stored in the request context. Handlers can update parts of it
+// through the exported helpers in this file.
+type requestState struct {
+	logger       *zap.Logger
+	requestID    string
+	recorder     *statusRecorder
+	logicalError atomic.Bool
+	// elevatedLevel is the minimum level of the completion log line requested using ElevateLevel, stored as the offset
+	// from zapcore.DebugLevel plus one so the zero value means no level was requested.
+	elevatedLevel atomic.Int32
+	// span is the span context recorded using RecordSpan, nil if none was recorded.
+	span atomic.Pointer[trace.SpanContext]
+}
+
+// elevation returns the minimum level of the completion log line requested using ElevateLevel.
+func (s *requestState) elevation() (zapcore.Level, bool) {
+	stored := s.elevatedLevel.Load()
+	if stored == 0 {
+		return 0, false
+	}
+	return zapcore.Level(stored - 1 + int32(zapcore.DebugLevel)), true
+}
+
+func injectRequestStateInContext(req *http.Request, state *requestState) *http.Request {
+	ctx := context.WithValue(req.Context(), requestStateContextKey, state)
 	return req.WithContext(ctx)
 }
 
+func requestStateFromContext(ctx context.Context) (*requestState, bool) {
+	state, ok := ctx.Value(requestStateContextKey).(*requestState)
+	return state, ok
+}
+
 func FromContext(ctx context.Context) *zap.Logger {
-	l, ok := ctx.Value(loggerContextKey).(*zap.Logger)
+	state, ok := requestStateFromContext(ctx)
 	if !ok {
 		// Logger is not injected in the context, use the default global logger
-		l = zap.L()
+		l := zap.L()
 		l.Debug("FromContext is used outside of a HTTP request context. Make sure the HTTP handler is wrapped in a logging handler.")
+		return l
+	}
+	return state.logger
+}
+
+// ErrNoLoggerInContext is returned by FromContextStrict when the context does not contain a per-request logger.
+var ErrNoLoggerInContext = errors.New("zaphttp: no request logger in context")
+
+// FromContextStrict returns the per-request logger stored in the context by the handler. Unlike FromContext it does not
+// fall back to the global logger, ErrNoLoggerInContext is returned if the context does not belong to a request handled
+// by a logging handler.
+func FromContextStrict(ctx context.Context) (*zap.Logger, error) {
+	state, ok := requestStateFromContext(ctx)
+	if !ok {
+		return nil, ErrNoLoggerInContext
+	}
+	return state.logger, nil
+}
+
+// MarkLogicalError marks the request as failed, even though the handler responds with a successful status code. This is
+// useful for APIs that return errors in the body of a 200 response. Use IsLogicalError in the classifier passed to
+// WithSuccessErrorClassifier to log these requests as errors.
+// MarkLogicalError is a no-op outside of a HTTP request context.
+func MarkLogicalError(ctx context.Context) {
+	if state, ok := requestStateFromContext(ctx); ok {
+		state.logicalError.Store(true)
+	}
+}
+
+// IsLogicalError returns true if MarkLogicalError was called for the request.
+func IsLogicalError(ctx context.Context) bool {
+	state, ok := requestStateFromContext(ctx)
+	return ok && state.logicalError.Load()
+}
+
+// ElevateLevel raises the level of the log line written when the request completes to at least level. Handlers can use
+// this to flag requests that are interesting even though the status code does not show it, for example a failed login
+// attempt that intentionally returns a 200. The level is never lowered, the highest requested level is used.
+// ElevateLevel is a no-op outside of a HTTP request context.
+func ElevateLevel(ctx context.Context, level zapcore.Level) {
+	state, ok := requestStateFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	stored := int32(level) - int32(zapcore.DebugLevel) + 1
+	for {
+		current := state.elevatedLevel.Load()
+		if current >= stored || state.elevatedLevel.CompareAndSwap(current, stored) {
+			return
+		}
+	}
+}
+
+// RecordSpan records the span in ctx for the request, so its trace fields are added to the log line written when the
+// request completes. Use this when the span is started inside the handler, for example by a tracing middleware that is
+// wrapped by the zaphttp handler, since the request context seen by the zaphttp handler does not contain it. Only has
+// an effect if WithLateTraceFields is used. RecordSpan is a no-op outside of a HTTP request context or if ctx does not
+// contain a valid span.
+func RecordSpan(ctx context.Context) {
+	state, ok := requestStateFromContext(ctx)
+	if !ok {
+		return
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		state.span.Store(&spanCtx)
+	}
+}
+
+// CaptureSpan returns a handler that records the span of the request using RecordSpan before calling next. Place it
+// after the middleware that starts the span:
+//
+//	zaphttp.NewHandler(zaphttp.WithLateTraceFields())(otelhttp.NewHandler(zaphttp.CaptureSpan(mux), "server"))
+func CaptureSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		RecordSpan(req.Context())
+		next.ServeHTTP(w, req)
+	})
+}
+
+// RequestIDFromContext returns the ID of the request, as read or generated by WithRequestID. Returns an empty string
+// outside of a HTTP request context or if WithRequestID is not used.
+func RequestIDFromContext(ctx context.Context) string {
+	if state, ok := requestStateFromContext(ctx); ok {
+		return state.requestID
+	}
+	return ""
+}
+
+// StatusFromContext returns the status code written by the handler so far. This can be used by code running after the
+// handler wrote its response, like a deferred function or a middleware between zaphttp and the handler, without
+// wrapping the response writer again. Returns false if no status code was written yet or outside of a HTTP request
+// context. Like the response writer itself, it must not be used concurrently with writes to the response.
+func StatusFromContext(ctx context.Context) (int, bool) {
+	state, ok := requestStateFromContext(ctx)
+	if !ok || state.recorder == nil || !state.recorder.writeHeaderCalled {
+		return 0, false
 	}
-	return l
+	return state.recorder.StatusCode, true
 }
@@ -8,6 +8,8 @@ import (
 
 	"github.com/marnixbouhuis/zaphttp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
@@ -86,3 +88,216 @@ func TestFromContext(t *testing.T) {
 		assert.Equal(t, "test message", logs.All()[1].Message)
 	})
 }
+
+func TestFromContextStrict(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should return the per request logger inside the request context", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithPerRequestLogger(func(parent *zap.Logger, _ *http.Request) *zap.Logger {
+				return parent.With(zap.String("request_id", "test-123"))
+			}),
+		)
+
+		var err error
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var l *zap.Logger
+			l, err = zaphttp.FromContextStrict(r.Context())
+			if err == nil {
+				l.Info("test message")
+			}
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, logs.Len())
+		assert.Equal(t, "test message", logs.All()[0].Message)
+		assert.Equal(t, "test-123", logs.All()[0].ContextMap()["request_id"])
+	})
+
+	t.Run("Should return an error outside of a request context", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := zaphttp.FromContextStrict(context.Background())
+		require.ErrorIs(t, err, zaphttp.ErrNoLoggerInContext)
+		assert.Nil(t, l)
+	})
+}
+
+func TestStatusFromContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should return the status code written by the handler", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+		)
+
+		// Middleware between zaphttp and the handler that reads the status after the handler is done.
+		var before, after int
+		var beforeOK, afterOK bool
+		metricsMiddleware := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				before, beforeOK = zaphttp.StatusFromContext(r.Context())
+				next.ServeHTTP(w, r)
+				after, afterOK = zaphttp.StatusFromContext(r.Context())
+			})
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(metricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))).ServeHTTP(rec, req)
+
+		assert.False(t, beforeOK)
+		assert.Equal(t, 0, before)
+		assert.True(t, afterOK)
+		assert.Equal(t, http.StatusTeapot, after)
+	})
+
+	t.Run("Should return false outside of a request context", func(t *testing.T) {
+		t.Parallel()
+
+		status, ok := zaphttp.StatusFromContext(context.Background())
+		assert.False(t, ok)
+		assert.Equal(t, 0, status)
+	})
+}
+
+func TestElevateLevel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should raise the level of the completion line", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/suspicious":
+				zaphttp.ElevateLevel(req.Context(), zapcore.ErrorLevel)
+				zaphttp.ElevateLevel(req.Context(), zapcore.WarnLevel)
+				w.WriteHeader(http.StatusOK)
+			case "/error":
+				// Elevation never lowers the level.
+				zaphttp.ElevateLevel(req.Context(), zapcore.WarnLevel)
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		}))
+
+		for _, path := range []string{"/suspicious", "/error", "/"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		lines := logs.All()
+		require.Len(t, lines, 3)
+		assert.Equal(t, zapcore.ErrorLevel, lines[0].Level)
+		assert.Equal(t, zapcore.ErrorLevel, lines[1].Level)
+		assert.Equal(t, zapcore.InfoLevel, lines[2].Level)
+	})
+
+	t.Run("Should be a no-op outside of a request context", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NotPanics(t, func() {
+			zaphttp.ElevateLevel(context.Background(), zapcore.ErrorLevel)
+		})
+	})
+}
+
+func TestRecordSpan(t *testing.T) {
+	t.Parallel()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	// Tracing middleware that starts the span after the zaphttp handler received the request.
+	tracingMiddleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx)))
+		})
+	}
+
+	tests := []struct {
+		name        string
+		opts        []zaphttp.HandlerOption
+		capture     bool
+		expectTrace bool
+	}{
+		{
+			name:        "Should log the recorded span",
+			opts:        []zaphttp.HandlerOption{zaphttp.WithLateTraceFields()},
+			capture:     true,
+			expectTrace: true,
+		},
+		{
+			name:    "Should not log the span without recording it",
+			opts:    []zaphttp.HandlerOption{zaphttp.WithLateTraceFields()},
+			capture: false,
+		},
+		{
+			name:    "Should not log the recorded span when disabled",
+			capture: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				zaphttp.FromContext(req.Context()).Info("handling request")
+				w.WriteHeader(http.StatusOK)
+			})
+			if tt.capture {
+				handler = zaphttp.CaptureSpan(handler)
+			}
+
+			opts := append([]zaphttp.HandlerOption{zaphttp.WithLogger(logger)}, tt.opts...)
+			requestLogger := zaphttp.NewHandler(opts...)
+			requestLogger(tracingMiddleware(handler)).ServeHTTP(
+				httptest.NewRecorder(),
+				httptest.NewRequest(http.MethodGet, "/", nil),
+			)
+
+			lines := logs.All()
+			require.Len(t, lines, 2)
+			completion := lines[1].ContextMap()
+
+			// The logger used by the handler is never changed.
+			assert.NotContains(t, lines[0].ContextMap(), "trace")
+			if !tt.expectTrace {
+				assert.NotContains(t, completion, "trace")
+				return
+			}
+			assert.Equal(t, map[string]interface{}{
+				"id":      "0c22384e5a0000000000000000000000",
+				"sampled": true,
+			}, completion["trace"])
+			assert.Equal(t, map[string]interface{}{
+				"id": "2b15000000000000",
+			}, completion["span"])
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// And returns a PerRequestFilterFunc that logs a request only if all filters return true. Filters are called in order
+// and evaluation stops at the first filter that returns false. And without filters logs every request.
+func And(filters ...PerRequestFilterFunc) PerRequestFilterFunc {
+	return func(req *http.Request, level zapcore.Level) bool {
+		for _, filter := range filters {
+			if !filter(req, level) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a PerRequestFilterFunc that logs a request if any of the filters returns true. Filters are called in
+// order and evaluation stops at the first filter that returns true. Or without filters logs no requests.
+func Or(filters ...PerRequestFilterFunc) PerRequestFilterFunc {
+	return func(req *http.Request, level zapcore.Level) bool {
+		for _, filter := range filters {
+			if filter(req, level) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a PerRequestFilterFunc that inverts the result of filter.
+func Not(filter PerRequestFilterFunc) PerRequestFilterFunc {
+	return func(req *http.Request, level zapcore.Level) bool {
+		return !filter(req, level)
+	}
+}
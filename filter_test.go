@@ -0,0 +1,81 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFilterCombinators(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	yes := func(_ *http.Request, _ zapcore.Level) bool { return true }
+	no := func(_ *http.Request, _ zapcore.Level) bool { return false }
+	mustNotBeCalled := func(_ *http.Request, _ zapcore.Level) bool {
+		t.Error("filter should not be called")
+		return false
+	}
+
+	t.Run("And", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, zaphttp.And()(req, zapcore.InfoLevel))
+		assert.True(t, zaphttp.And(yes, yes)(req, zapcore.InfoLevel))
+		assert.False(t, zaphttp.And(yes, no)(req, zapcore.InfoLevel))
+		assert.False(t, zaphttp.And(no, mustNotBeCalled)(req, zapcore.InfoLevel))
+	})
+
+	t.Run("Or", func(t *testing.T) {
+		t.Parallel()
+
+		assert.False(t, zaphttp.Or()(req, zapcore.InfoLevel))
+		assert.False(t, zaphttp.Or(no, no)(req, zapcore.InfoLevel))
+		assert.True(t, zaphttp.Or(no, yes)(req, zapcore.InfoLevel))
+		assert.True(t, zaphttp.Or(yes, mustNotBeCalled)(req, zapcore.InfoLevel))
+	})
+
+	t.Run("Not", func(t *testing.T) {
+		t.Parallel()
+
+		assert.False(t, zaphttp.Not(yes)(req, zapcore.InfoLevel))
+		assert.True(t, zaphttp.Not(no)(req, zapcore.InfoLevel))
+	})
+
+	t.Run("Should pass the request and level through", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.DebugLevel)
+		logger := zap.New(core)
+
+		isHealthCheck := func(req *http.Request, _ zapcore.Level) bool { return req.URL.Path == "/healthz" }
+		isError := func(_ *http.Request, level zapcore.Level) bool { return level >= zapcore.ErrorLevel }
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithPerRequestFilter(zaphttp.And(zaphttp.Not(isHealthCheck), isError)),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Has("fail") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		for _, target := range []string{"/", "/?fail", "/healthz", "/healthz?fail"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		}
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, zapcore.ErrorLevel, lines[0].Level)
+	})
+}
@@ -1,6 +1,7 @@
 package zaphttp
 
 import (
+	"net"
 	"net/http"
 	"time"
 
@@ -13,6 +14,44 @@ type ResponseInfo struct {
 	ContentType string
 	Start       time.Time
 	Latency     time.Duration
+	// TimeToFirstByte is the time between the start of the request and the moment the handler wrote the response
+	// header. Zero if no response header was written.
+	TimeToFirstByte time.Duration
+	// ContentLanguage is the Content-Language header sent in the response.
+	ContentLanguage string
+	// BytesWritten is the number of response body bytes written by the handler.
+	BytesWritten int64
+	// Hijacked is true when the handler took over the connection, for example for WebSockets. StatusCode is only set
+	// if the handler wrote a status before hijacking.
+	Hijacked bool
+	// ClientHost is the host part of the client address (req.RemoteAddr), as returned by the remote address parser.
+	ClientHost string
+	// ClientPort is the port part of the client address, 0 if the address does not contain a port.
+	ClientPort int
+	// ServerAddress is the address of the server as returned by the resolver configured using WithServerAddress. Empty
+	// if no resolver is configured, formatters fall back to the local address of the connection.
+	ServerAddress string
+	// RequestHeaders contains the request headers selected using WithRequestHeaders, keyed by the lowercased header
+	// name. Multiple values of the same header are joined with commas.
+	RequestHeaders map[string]string
+	// ResponseHeaders contains the response headers selected using WithResponseHeaders, keyed by the lowercased header
+	// name. The headers are captured when the response header is written, headers set afterwards are not included.
+	ResponseHeaders map[string]string
+	// RequestBody contains the part of the request body read by the handler, up to the maximum size configured using
+	// WithRequestBodyLogging. Nil if request body logging is disabled or the handler did not read the body.
+	RequestBody []byte
+	// RequestBodyTruncated is true if the handler read more of the request body than RequestBody contains.
+	RequestBodyTruncated bool
+	// Trailers contains the response trailers set by the handler, only captured if WithGRPCGatewayFields is used.
+	Trailers http.Header
+	// Panicked is true when the handler did not return normally, either because it panicked or because
+	// runtime.Goexit() was called.
+	Panicked bool
+	// PanicValue is the value the handler panicked with, only set if both WithErrorFieldFromPanic and WithRecover are
+	// used.
+	PanicValue any
+	// PanicStack is the stack trace of the goroutine that panicked, only set if WithErrorFieldFromPanic is used.
+	PanicStack []byte
 }
 
 type TraceFormatter interface {
@@ -28,4 +67,33 @@ type Formatter interface {
 	RequestFormatter
 }
 
+// LabelFormatter is a Formatter that also returns low-cardinality labels for a request, for log backends that index
+// labels separately from the log line, like Grafana Loki. Labels are not added to the log fields, a custom zap core or
+// log shipper can use them to select the stream of a log line.
+type LabelFormatter interface {
+	Formatter
+	GetLabels(req *http.Request, res *ResponseInfo) map[string]string
+}
+
 var DefaultFormatter = ElasticCommonSchemaFormatter
+
+// clientHost returns the parsed client host, falling back to the raw remote address if the response info does not
+// contain a parsed host. This is the case when a formatter is used outside the handler.
+func clientHost(req *http.Request, res *ResponseInfo) string {
+	if res.ClientHost != "" {
+		return res.ClientHost
+	}
+	return req.RemoteAddr
+}
+
+// serverAddress returns the configured server address, falling back to the local address of the connection the request
+// was received on. Returns an empty string if neither is available.
+func serverAddress(req *http.Request, res *ResponseInfo) string {
+	if res.ServerAddress != "" {
+		return res.ServerAddress
+	}
+	if localAddr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return localAddr.String()
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package zaphttp
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// cloudWatchMetricNamespace is the default namespace of the embedded metrics.
+const cloudWatchMetricNamespace = "zaphttp"
+
+// cloudWatchEMF represents the "_aws" metadata object of the CloudWatch embedded metric format.
+// See: https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+type cloudWatchEMF struct {
+	Timestamp int64
+	Namespace string
+}
+
+func (e *cloudWatchEMF) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt64("Timestamp", e.Timestamp)
+	return enc.AddArray("CloudWatchMetrics", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		return enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("Namespace", e.Namespace)
+			err := enc.AddArray("Dimensions", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+				return enc.AppendArray(zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+					enc.AppendString("statusClass")
+					return nil
+				}))
+			}))
+			if err != nil {
+				return err
+			}
+			return enc.AddArray("Metrics", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+				if err := enc.AppendObject(cloudWatchMetric{Name: "latencyMs", Unit: "Milliseconds"}); err != nil {
+					return err
+				}
+				return enc.AppendObject(cloudWatchMetric{Name: "requestCount", Unit: "Count"})
+			}))
+		}))
+	}))
+}
+
+// cloudWatchMetric is the definition of a metric in the embedded metric format.
+type cloudWatchMetric struct {
+	Name string
+	Unit string
+}
+
+func (m cloudWatchMetric) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("Name", m.Name)
+	enc.AddString("Unit", m.Unit)
+	return nil
+}
+
+type cloudWatchFormatter struct {
+	emf          bool
+	emfNamespace string
+}
+
+var _ Formatter = &cloudWatchFormatter{}
+
+// CloudWatchFormatterOption configures the formatter returned by NewCloudWatchFormatter.
+type CloudWatchFormatterOption func(*cloudWatchFormatter)
+
+// WithCloudWatchEmbeddedMetrics adds the "_aws" metadata object of the embedded metric format, so CloudWatch creates
+// the "latencyMs" and "requestCount" metrics from the logs. The metrics have a "statusClass" dimension ("2xx", "4xx",
+// etc.), a string field added to the logs for this purpose. Namespace defaults to "zaphttp" if empty.
+// See: https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format.html
+func WithCloudWatchEmbeddedMetrics(namespace string) CloudWatchFormatterOption {
+	return func(f *cloudWatchFormatter) {
+		f.emf = true
+		f.emfNamespace = namespace
+		if f.emfNamespace == "" {
+			f.emfNamespace = cloudWatchMetricNamespace
+		}
+	}
+}
+
+// NewCloudWatchFormatter returns a log field formatter that logs flat fields that are easy to query using CloudWatch
+// Logs Insights: "httpMethod", "statusCode", "latencyMs", "path" and "sourceIp". Traces are logged as "traceId" using
+// the AWS X-Ray trace ID format ("1-5759e988-bd862e3fe1be46a994272793") and "spanId".
+func NewCloudWatchFormatter(opts ...CloudWatchFormatterOption) Formatter {
+	f := &cloudWatchFormatter{}
+	for _, fn := range opts {
+		fn(f)
+	}
+	return f
+}
+
+func (*cloudWatchFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String("traceId", xrayTraceID(spanCtx.TraceID())),
+		zap.String("spanId", spanCtx.SpanID().String()),
+	}
+}
+
+func (f *cloudWatchFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	fields := []zap.Field{
+		zap.String("httpMethod", req.Method),
+		zap.Int("statusCode", res.StatusCode),
+		zap.Float64("latencyMs", float64(res.Latency.Microseconds())/1000),
+		zap.String("path", req.URL.Path),
+		zap.String("sourceIp", clientHost(req, res)),
+	}
+
+	if f.emf {
+		fields = append(fields,
+			zap.String("statusClass", strconv.Itoa(res.StatusCode/100)+"xx"),
+			zap.Int("requestCount", 1),
+			zap.Object("_aws", &cloudWatchEMF{
+				Timestamp: res.Start.Add(res.Latency).UnixMilli(),
+				Namespace: f.emfNamespace,
+			}),
+		)
+	}
+
+	return fields
+}
+
+// xrayTraceID formats a trace ID using the AWS X-Ray format: the version, the first 8 hexadecimal digits (the epoch
+// time in seconds for IDs generated by X-Ray) and the remaining 24 digits, separated by dashes.
+// See: https://docs.aws.amazon.com/xray/latest/devguide/xray-concepts.html#xray-concepts-traces
+func xrayTraceID(traceID trace.TraceID) string {
+	id := traceID.String()
+	return "1-" + id[:8] + "-" + id[8:]
+}
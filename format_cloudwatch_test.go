@@ -0,0 +1,102 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCloudWatchFormatter(t *testing.T) {
+	t.Parallel()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x57, 0x59, 0xe9, 0x88, 0xbd, 0x86, 0x2e, 0x3f, 0xe1, 0xbe, 0x46, 0xa9, 0x94, 0x27, 0x27, 0x93},
+		SpanID:     trace.SpanID{0x53, 0x99, 0x5c, 0x3f, 0x42, 0xcd, 0x8a, 0xd8},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	t.Run("Should log flat fields", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		formatter := zaphttp.NewCloudWatchFormatter()
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithTraceFormatter(formatter),
+			zaphttp.WithRequestFormatter(formatter),
+			zaphttp.WithClock(func() time.Time { return now }),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/orders?id=1", nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			now = now.Add(12500 * time.Microsecond)
+			w.WriteHeader(http.StatusCreated)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.Equal(t, map[string]interface{}{
+			"traceId":    "1-5759e988-bd862e3fe1be46a994272793",
+			"spanId":     "53995c3f42cd8ad8",
+			"httpMethod": http.MethodPost,
+			"statusCode": int64(http.StatusCreated),
+			"latencyMs":  12.5,
+			"path":       "/orders",
+			"sourceIp":   "203.0.113.7",
+		}, lines[0].ContextMap())
+	})
+
+	t.Run("Should add embedded metric format metadata", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRequestFormatter(zaphttp.NewCloudWatchFormatter(zaphttp.WithCloudWatchEmbeddedMetrics("shop"))),
+			zaphttp.WithClock(func() time.Time { return now }),
+		)
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			now = now.Add(time.Second)
+			w.WriteHeader(http.StatusNotFound)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+
+		fields := lines[0].ContextMap()
+		assert.Equal(t, "4xx", fields["statusClass"])
+		assert.Equal(t, int64(1), fields["requestCount"])
+		assert.Equal(t, map[string]interface{}{
+			"Timestamp": now.UnixMilli(),
+			"CloudWatchMetrics": []interface{}{
+				map[string]interface{}{
+					"Namespace":  "shop",
+					"Dimensions": []interface{}{[]interface{}{"statusClass"}},
+					"Metrics": []interface{}{
+						map[string]interface{}{"Name": "latencyMs", "Unit": "Milliseconds"},
+						map[string]interface{}{"Name": "requestCount", "Unit": "Count"},
+					},
+				},
+			},
+		}, fields["_aws"])
+	})
+}
@@ -0,0 +1,42 @@
+package zaphttp
+
+import (
+	"encoding/binary"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type datadogFormatter struct{}
+
+var _ Formatter = &datadogFormatter{}
+
+// NewDatadogFormatter returns a log field formatter that logs traces and requests using the Datadog standard
+// attributes. See: https://docs.datadoghq.com/logs/log_configuration/attributes_naming_convention/
+//
+// Datadog only accepts 64-bit trace and span IDs formatted as decimal strings. OpenTelemetry trace IDs are 128-bit, only
+// the lower 64 bits of the trace ID are logged as "dd.trace_id". This matches how Datadog correlates OpenTelemetry
+// traces with logs.
+func NewDatadogFormatter() Formatter {
+	return &datadogFormatter{}
+}
+
+func (*datadogFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	traceID := spanCtx.TraceID()
+	spanID := spanCtx.SpanID()
+	return []zap.Field{
+		zap.String("dd.trace_id", strconv.FormatUint(binary.BigEndian.Uint64(traceID[8:]), 10)),
+		zap.String("dd.span_id", strconv.FormatUint(binary.BigEndian.Uint64(spanID[:]), 10)),
+	}
+}
+
+func (*datadogFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	return []zap.Field{
+		zap.String("http.method", req.Method),
+		zap.Int("http.status_code", res.StatusCode),
+		zap.String("http.url", req.URL.Redacted()),
+		zap.Int64("duration", res.Latency.Nanoseconds()),
+	}
+}
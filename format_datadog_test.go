@@ -0,0 +1,59 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDatadogFormatter(t *testing.T) {
+	t.Parallel()
+
+	formatter := zaphttp.NewDatadogFormatter()
+	req := httptest.NewRequest(http.MethodGet, "/users?page=2", nil)
+
+	t.Run("Should log the lower 64 bits of the IDs as decimal strings", func(t *testing.T) {
+		t.Parallel()
+
+		spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+			// The upper 64 bits are not part of the Datadog trace ID.
+			TraceID: trace.TraceID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0x30, 0x39},
+			SpanID:  trace.SpanID{0x80, 0, 0, 0, 0, 0, 0, 1},
+		})
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		zap.New(core).Info("test", formatter.GetTraceFields(req, spanCtx)...)
+
+		assert.Equal(t, map[string]interface{}{
+			"dd.trace_id": "12345",
+			"dd.span_id":  "9223372036854775809",
+		}, logs.All()[0].ContextMap())
+	})
+
+	t.Run("Should log the standard HTTP attributes", func(t *testing.T) {
+		t.Parallel()
+
+		res := &zaphttp.ResponseInfo{
+			StatusCode: http.StatusOK,
+			Latency:    1500 * time.Microsecond,
+		}
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		zap.New(core).Info("test", formatter.GetRequestFields(req, res)...)
+
+		assert.Equal(t, map[string]interface{}{
+			"http.method":      http.MethodGet,
+			"http.status_code": int64(http.StatusOK),
+			"http.url":         "/users?page=2",
+			"duration":         int64(1500000),
+		}, logs.All()[0].ContextMap())
+	})
+}
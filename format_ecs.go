@@ -1,10 +1,12 @@
 package zaphttp
 
 import (
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
-	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel/trace"
@@ -19,11 +21,15 @@ type ecsTrace struct {
 	ID string
 	// Sampled indicates if a trace has been sampled or not. It is not a standard field but still a nice to have in logs.
 	Sampled bool
+	// State is the W3C tracestate carrying vendor specific trace data. It is not a standard field, it is only logged
+	// when set.
+	State string
 }
 
 func (t *ecsTrace) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddString("id", t.ID)
 	enc.AddBool("sampled", t.Sampled)
+	addNonEmptyString(enc, "state", t.State)
 	return nil
 }
 
@@ -57,15 +63,49 @@ func (e *ecsEvent) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	return nil
 }
 
+// ecsLatency represents the latency of a request split into its components. It is not a standard field.
+type ecsLatency struct {
+	// Total is the total latency of the request.
+	Total time.Duration
+	// TimeToFirstByte is the time until the response header was written, zero if unavailable.
+	TimeToFirstByte time.Duration
+}
+
+func (l *ecsLatency) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt64("total_ns", l.Total.Nanoseconds())
+	if l.TimeToFirstByte > 0 {
+		enc.AddInt64("ttfb_ns", l.TimeToFirstByte.Nanoseconds())
+		// Processing is the time spent after the first byte, for example streaming the response body.
+		enc.AddInt64("processing_ns", (l.Total - l.TimeToFirstByte).Nanoseconds())
+	}
+	return nil
+}
+
 // ecsHTTPRequestBody represents HTTP request body info formatted for elastic common schema logging.
 // See: https://www.elastic.co/guide/en/ecs/current/ecs-http.html
 type ecsHTTPRequestBody struct {
 	// Bytes is the size of the request body, see: https://www.elastic.co/guide/en/ecs/current/ecs-http.html#field-http-request-body-bytes
 	Bytes int64
+	// Content is the start of the request body, see: https://www.elastic.co/guide/en/ecs/current/ecs-http.html#field-http-request-body-content
+	Content []byte
+	// Binary is true if the content is not text, it is logged base64 encoded.
+	Binary bool
+	// Truncated is true if the body is longer than Content. It is not a standard field, it is only logged when set.
+	Truncated bool
 }
 
 func (b *ecsHTTPRequestBody) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddInt64("bytes", b.Bytes)
+	if len(b.Content) > 0 {
+		if b.Binary {
+			enc.AddString("content", base64.StdEncoding.EncodeToString(b.Content))
+		} else {
+			enc.AddByteString("content", b.Content)
+		}
+		if b.Truncated {
+			enc.AddBool("truncated", true)
+		}
+	}
 	return nil
 }
 
@@ -80,6 +120,10 @@ type ecsHTTPRequest struct {
 	MimeType string
 	// Referrer is the referrer sent by the client, see: https://www.elastic.co/guide/en/ecs/current/ecs-http.html#field-http-request-referrer
 	Referrer string
+	// StructuredReferrer is the parsed referrer, it is logged instead of Referrer when set.
+	StructuredReferrer *ecsReferrer
+	// Headers contains the captured request headers. It is not a standard field, it is only logged when set.
+	Headers ecsHeaders
 }
 
 func (r *ecsHTTPRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
@@ -87,23 +131,103 @@ func (r *ecsHTTPRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 		return err
 	}
 	enc.AddString("method", r.Method)
-	enc.AddString("mime_type", r.MimeType)
-	enc.AddString("referrer", r.Referrer)
+	addNonEmptyString(enc, "mime_type", r.MimeType)
+	if r.StructuredReferrer != nil {
+		if err := enc.AddObject("referrer", r.StructuredReferrer); err != nil {
+			return err
+		}
+	} else {
+		addNonEmptyString(enc, "referrer", r.Referrer)
+	}
+	if len(r.Headers) > 0 {
+		if err := enc.AddObject("headers", r.Headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ecsHeaders represents captured HTTP headers, keyed by the lowercased header name.
+type ecsHeaders map[string]string
+
+func (h ecsHeaders) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, name := range sortedKeys(h) {
+		enc.AddString(name, h[name])
+	}
+	return nil
+}
+
+// ecsReferrer represents a parsed referrer. It is not a standard field, ECS defines the referrer as a plain string.
+type ecsReferrer struct {
+	// Original is the unparsed referrer.
+	Original string
+	// Host is the host of the referrer URL.
+	Host string
+	// Path is the path of the referrer URL.
+	Path string
+}
+
+func (r *ecsReferrer) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("original", r.Original)
+	enc.AddString("host", r.Host)
+	enc.AddString("path", r.Path)
+	return nil
+}
+
+// parseReferrer parses a referrer into its components. Returns nil if the referrer is not an absolute URL.
+func parseReferrer(referrer string) *ecsReferrer {
+	if referrer == "" {
+		return nil
+	}
+
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+
+	return &ecsReferrer{
+		Original: referrer,
+		Host:     u.Host,
+		Path:     u.Path,
+	}
+}
+
+// ecsHTTPResponseBody represents HTTP response body info formatted for elastic common schema logging.
+// See: https://www.elastic.co/guide/en/ecs/current/ecs-http.html
+type ecsHTTPResponseBody struct {
+	// Bytes is the size of the response body, see: https://www.elastic.co/guide/en/ecs/current/ecs-http.html#field-http-response-body-bytes
+	Bytes int64
+}
+
+func (b *ecsHTTPResponseBody) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt64("bytes", b.Bytes)
 	return nil
 }
 
 // ecsHTTPResponse represents HTTP response info formatted for elastic common schema logging.
 // See: https://www.elastic.co/guide/en/ecs/current/ecs-http.html
 type ecsHTTPResponse struct {
+	// Body contains information about the response body, see: https://www.elastic.co/guide/en/ecs/current/ecs-http.html
+	Body *ecsHTTPResponseBody
 	// MimeType is the content type sent by the server, see: https://www.elastic.co/guide/en/ecs/current/ecs-http.html#field-http-response-mime-type
 	MimeType string
 	// StatusCode is the response code sent by the server, see: https://www.elastic.co/guide/en/ecs/current/ecs-http.html#field-http-response-status-code
 	StatusCode int
+	// Headers contains the captured response headers. It is not a standard field, it is only logged when set.
+	Headers ecsHeaders
 }
 
 func (r *ecsHTTPResponse) MarshalLogObject(enc zapcore.ObjectEncoder) error {
-	enc.AddString("mime_type", r.MimeType)
+	if err := enc.AddObject("body", r.Body); err != nil {
+		return err
+	}
+	addNonEmptyString(enc, "mime_type", r.MimeType)
 	enc.AddInt("status_code", r.StatusCode)
+	if len(r.Headers) > 0 {
+		if err := enc.AddObject("headers", r.Headers); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -167,10 +291,15 @@ func (u *ecsUserAgent) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 type ecsClient struct {
 	// Address is the address the client connected from, see: https://www.elastic.co/guide/en/ecs/current/ecs-client.html#field-client-address
 	Address string
+	// Port is the port the client connected from, see: https://www.elastic.co/guide/en/ecs/current/ecs-client.html#field-client-port
+	Port int
 }
 
 func (c *ecsClient) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddString("address", c.Address)
+	if c.Port != 0 {
+		enc.AddInt("port", c.Port)
+	}
 	return nil
 }
 
@@ -186,15 +315,154 @@ func (s *ecsServer) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	return nil
 }
 
-type elasticCommonSchemaFormatter struct{}
+// ecsTLS represents TLS connection info formatted for elastic common schema logging.
+// See: https://www.elastic.co/guide/en/ecs/current/ecs-tls.html
+type ecsTLS struct {
+	State *tls.ConnectionState
+}
+
+func (t *ecsTLS) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	protocol, version := ecsTLSVersion(t.State.Version)
+	enc.AddBool("established", t.State.HandshakeComplete)
+	addNonEmptyString(enc, "version_protocol", protocol)
+	addNonEmptyString(enc, "version", version)
+	addNonEmptyString(enc, "cipher", tls.CipherSuiteName(t.State.CipherSuite))
+	addNonEmptyString(enc, "next_protocol", t.State.NegotiatedProtocol)
+	if t.State.ServerName != "" {
+		// ECS logs the SNI server name sent by the client as tls.client.server_name.
+		return enc.AddObject("client", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("server_name", t.State.ServerName)
+			return nil
+		}))
+	}
+	return nil
+}
+
+// ecsTLSVersion splits the TLS version in the protocol ("tls" or "ssl") and the version number ("1.3"), see:
+// https://www.elastic.co/guide/en/ecs/current/ecs-tls.html#field-tls-version
+func ecsTLSVersion(version uint16) (string, string) {
+	if version == 0 {
+		return "", ""
+	}
+	// tls.VersionName returns names like "TLS 1.3" and "SSLv3", or a hexadecimal representation for unknown versions.
+	name := tls.VersionName(version)
+	if v, ok := strings.CutPrefix(name, "TLS "); ok {
+		return "tls", v
+	}
+	if v, ok := strings.CutPrefix(name, "SSLv"); ok {
+		return "ssl", v + ".0"
+	}
+	return "", name
+}
+
+// ecsError represents error info formatted for elastic common schema logging.
+// See: https://www.elastic.co/guide/en/ecs/current/ecs-error.html
+type ecsError struct {
+	// Type is the Go type of the error, see: https://www.elastic.co/guide/en/ecs/current/ecs-error.html#field-error-type
+	Type string
+	// Message is the error message, see: https://www.elastic.co/guide/en/ecs/current/ecs-error.html#field-error-message
+	Message string
+	// StackTrace is the stack trace of the error, see: https://www.elastic.co/guide/en/ecs/current/ecs-error.html#field-error-stack-trace
+	StackTrace []byte
+}
+
+func (e *ecsError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if e.Type != "" {
+		enc.AddString("type", e.Type)
+	}
+	if e.Message != "" {
+		enc.AddString("message", e.Message)
+	}
+	if len(e.StackTrace) > 0 {
+		enc.AddByteString("stack_trace", e.StackTrace)
+	}
+	return nil
+}
+
+// panicError returns the ECS error object for the value a handler panicked with. Values that are not an error are
+// logged with their Go type and formatted value as message. Only the stack trace is logged if the value is not known.
+func panicError(value any, stack []byte) *ecsError {
+	if value == nil {
+		return &ecsError{StackTrace: stack}
+	}
+	message := fmt.Sprint(value)
+	if err, ok := value.(error); ok {
+		message = err.Error()
+	}
+	return &ecsError{
+		Type:       fmt.Sprintf("%T", value),
+		Message:    message,
+		StackTrace: stack,
+	}
+}
+
+type elasticCommonSchemaFormatter struct {
+	structuredReferrer bool
+	traceState         bool
+	structuredLatency  bool
+	tlsFields          bool
+}
 
 var ElasticCommonSchemaFormatter Formatter = &elasticCommonSchemaFormatter{}
 
-func (*elasticCommonSchemaFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+// ElasticCommonSchemaFormatterOption configures the formatter returned by NewElasticCommonSchemaFormatter.
+type ElasticCommonSchemaFormatterOption func(*elasticCommonSchemaFormatter)
+
+// WithECSStructuredReferrer logs http.request.referrer as an object containing the original referrer and its parsed
+// host and path. Referrers that can not be parsed as absolute URL are still logged as plain string.
+// Note that ECS defines the referrer as a keyword, an index using the ECS mapping will reject the object.
+func WithECSStructuredReferrer() ElasticCommonSchemaFormatterOption {
+	return func(f *elasticCommonSchemaFormatter) {
+		f.structuredReferrer = true
+	}
+}
+
+// WithECSTraceState logs the W3C tracestate of the span as trace.state. This contains vendor specific trace data, like
+// sampling priorities, and is useful for debugging tracing across vendors. Empty trace states are not logged.
+func WithECSTraceState() ElasticCommonSchemaFormatterOption {
+	return func(f *elasticCommonSchemaFormatter) {
+		f.traceState = true
+	}
+}
+
+// WithECSStructuredLatency logs a "latency" object containing the total latency ("total_ns"), the time to first byte
+// ("ttfb_ns") and the time spent after the first byte ("processing_ns"), in nanoseconds. The time to first byte and
+// processing time are omitted when the handler did not write a response header. event.duration is still logged.
+func WithECSStructuredLatency() ElasticCommonSchemaFormatterOption {
+	return func(f *elasticCommonSchemaFormatter) {
+		f.structuredLatency = true
+	}
+}
+
+// WithECSTLSFields logs the TLS version, cipher suite and server name (SNI) of HTTPS requests in the "tls" object.
+// Nothing is logged for plaintext HTTP requests.
+func WithECSTLSFields() ElasticCommonSchemaFormatterOption {
+	return func(f *elasticCommonSchemaFormatter) {
+		f.tlsFields = true
+	}
+}
+
+// NewElasticCommonSchemaFormatter returns an Elastic Common Schema formatter with custom options. Use
+// ElasticCommonSchemaFormatter for the default behaviour.
+func NewElasticCommonSchemaFormatter(opts ...ElasticCommonSchemaFormatterOption) Formatter {
+	f := &elasticCommonSchemaFormatter{}
+	for _, fn := range opts {
+		fn(f)
+	}
+	return f
+}
+
+func (f *elasticCommonSchemaFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	var traceState string
+	if f.traceState {
+		traceState = spanCtx.TraceState().String()
+	}
+
 	return []zap.Field{
 		zap.Object("trace", &ecsTrace{
 			ID:      spanCtx.TraceID().String(),
 			Sampled: spanCtx.IsSampled(),
+			State:   traceState,
 		}),
 		zap.Object("span", &ecsSpan{
 			ID: spanCtx.SpanID().String(),
@@ -202,44 +470,137 @@ func (*elasticCommonSchemaFormatter) GetTraceFields(_ *http.Request, spanCtx tra
 	}
 }
 
-func (*elasticCommonSchemaFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
-	var serverAddr string
-	if localAddr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
-		serverAddr = localAddr.String()
+// ecsRequestFields holds all objects logged for a request. They are allocated together, so formatting a request
+// results in a single allocation instead of one per object. The returned fields point into this struct.
+type ecsRequestFields struct {
+	event        ecsEvent
+	http         ecsHTTP
+	request      ecsHTTPRequest
+	requestBody  ecsHTTPRequestBody
+	response     ecsHTTPResponse
+	responseBody ecsHTTPResponseBody
+	url          ecsURL
+	client       ecsClient
+	server       ecsServer
+	latency      ecsLatency
+	tls          ecsTLS
+	userAgent    ecsUserAgent
+
+	// fields is the backing array of the returned fields, large enough for all fields that can be logged.
+	fields [9]zap.Field
+}
+
+func (f *elasticCommonSchemaFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	serverAddr := serverAddress(req, res)
+
+	var structuredReferrer *ecsReferrer
+	if f.structuredReferrer {
+		structuredReferrer = parseReferrer(req.Referer())
 	}
 
-	return []zap.Field{
-		zap.Object("event", &ecsEvent{
+	r := &ecsRequestFields{
+		event: ecsEvent{
 			Start:    res.Start,
 			Duration: res.Latency,
 			End:      res.Start.Add(res.Latency),
-		}),
-		zap.Object("http", &ecsHTTP{
-			Request: &ecsHTTPRequest{
-				Body: &ecsHTTPRequestBody{
-					Bytes: req.ContentLength,
-				},
-				Method:   req.Method,
-				MimeType: req.Header.Get("Content-Type"),
-				Referrer: req.Referer(),
-			},
-			Response: &ecsHTTPResponse{
-				MimeType:   res.ContentType,
-				StatusCode: res.StatusCode,
-			},
-			Version: fmt.Sprintf("%d.%d", req.ProtoMajor, req.ProtoMinor),
-		}),
-		zap.Object("url", &ecsURL{
+		},
+		requestBody: ecsHTTPRequestBody{
+			Bytes:     req.ContentLength,
+			Content:   res.RequestBody,
+			Truncated: res.RequestBodyTruncated,
+		},
+		request: ecsHTTPRequest{
+			Method:   req.Method,
+			MimeType: req.Header.Get("Content-Type"),
+			Referrer: req.Referer(),
+
+			StructuredReferrer: structuredReferrer,
+			Headers:            res.RequestHeaders,
+		},
+		responseBody: ecsHTTPResponseBody{
+			Bytes: res.BytesWritten,
+		},
+		response: ecsHTTPResponse{
+			MimeType:   res.ContentType,
+			StatusCode: res.StatusCode,
+			Headers:    res.ResponseHeaders,
+		},
+		http: ecsHTTP{
+			Version: ecsHTTPVersion(req),
+		},
+		url: ecsURL{
 			URL: req.URL,
-		}),
-		zap.Object("user_agent", &ecsUserAgent{
-			Original: req.UserAgent(),
-		}),
-		zap.Object("client", &ecsClient{
-			Address: req.RemoteAddr,
-		}),
-		zap.Object("server", &ecsServer{
+		},
+		client: ecsClient{
+			Address: clientHost(req, res),
+			Port:    res.ClientPort,
+		},
+		server: ecsServer{
 			Address: serverAddr,
-		}),
+		},
+	}
+	if len(res.RequestBody) > 0 {
+		r.requestBody.Binary = !isTextContentType(r.request.MimeType)
+	}
+	r.request.Body = &r.requestBody
+	r.response.Body = &r.responseBody
+	r.http.Request = &r.request
+	r.http.Response = &r.response
+
+	fields := append(r.fields[:0],
+		zap.Object("event", &r.event),
+		zap.Object("http", &r.http),
+		zap.Object("url", &r.url),
+		zap.Object("client", &r.client),
+		zap.Object("server", &r.server),
+	)
+
+	if f.structuredLatency {
+		r.latency = ecsLatency{
+			Total:           res.Latency,
+			TimeToFirstByte: res.TimeToFirstByte,
+		}
+		fields = append(fields, zap.Object("latency", &r.latency))
+	}
+
+	if res.PanicValue != nil || len(res.PanicStack) > 0 {
+		fields = append(fields, zap.Object("error", panicError(res.PanicValue, res.PanicStack)))
+	}
+
+	if f.tlsFields && req.TLS != nil {
+		r.tls = ecsTLS{
+			State: req.TLS,
+		}
+		fields = append(fields, zap.Object("tls", &r.tls))
+	}
+
+	if userAgent := req.UserAgent(); userAgent != "" {
+		r.userAgent = ecsUserAgent{
+			Original: userAgent,
+		}
+		fields = append(fields, zap.Object("user_agent", &r.userAgent))
+	}
+
+	return fields
+}
+
+// ecsHTTPVersion returns the HTTP version of the request, without allocating for the common versions.
+func ecsHTTPVersion(req *http.Request) string {
+	switch {
+	case req.ProtoMajor == 1 && req.ProtoMinor == 1:
+		return "1.1"
+	case req.ProtoMajor == 2 && req.ProtoMinor == 0:
+		return "2.0"
+	case req.ProtoMajor == 1 && req.ProtoMinor == 0:
+		return "1.0"
+	default:
+		return fmt.Sprintf("%d.%d", req.ProtoMajor, req.ProtoMinor)
+	}
+}
+
+// addNonEmptyString adds a string to the encoder, unless it is empty. Elastic prefers missing fields over empty ones.
+func addNonEmptyString(enc zapcore.ObjectEncoder, key, value string) {
+	if value != "" {
+		enc.AddString(key, value)
 	}
 }
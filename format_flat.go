@@ -0,0 +1,35 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type flatFormatter struct{}
+
+var _ Formatter = &flatFormatter{}
+
+// NewFlatFormatter returns a log field formatter that only logs a few top-level fields: "method", "path", "status",
+// "duration" and "remote_ip" for requests and "trace_id" for traces. This is easy to read in console logs during local
+// development.
+func NewFlatFormatter() Formatter {
+	return &flatFormatter{}
+}
+
+func (*flatFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String("trace_id", spanCtx.TraceID().String()),
+	}
+}
+
+func (*flatFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	return []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("path", req.URL.Path),
+		zap.Int("status", res.StatusCode),
+		zap.Duration("duration", res.Latency),
+		zap.String("remote_ip", clientHost(req, res)),
+	}
+}
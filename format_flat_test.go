@@ -0,0 +1,57 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFlatFormatter(t *testing.T) {
+	t.Parallel()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithTraceFormatter(zaphttp.NewFlatFormatter()),
+		zaphttp.WithRequestFormatter(zaphttp.NewFlatFormatter()),
+	)
+
+	req := httptest.NewRequest(http.MethodPut, "/users/1?force=true", nil)
+	req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+
+	fields := lines[0].ContextMap()
+	assert.IsType(t, time.Duration(0), fields["duration"])
+	delete(fields, "duration")
+	assert.Equal(t, map[string]interface{}{
+		"trace_id":  "0c22384e5a0000000000000000000000",
+		"method":    http.MethodPut,
+		"path":      "/users/1",
+		"status":    int64(http.StatusNoContent),
+		"remote_ip": "192.0.2.1",
+	}, fields)
+}
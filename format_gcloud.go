@@ -2,9 +2,10 @@ package zaphttp
 
 import (
 	"fmt"
-	"net"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
@@ -18,6 +19,7 @@ type gcloudHTTPRequest struct {
 	RequestURL    string
 	RequestSize   string
 	Status        int
+	ResponseSize  string
 	UserAgent     string
 	RemoteIP      string
 	ServerIP      string
@@ -31,6 +33,7 @@ func (h *gcloudHTTPRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddString("requestUrl", h.RequestURL)
 	enc.AddString("requestSize", h.RequestSize)
 	enc.AddInt("status", h.Status)
+	enc.AddString("responseSize", h.ResponseSize)
 	enc.AddString("userAgent", h.UserAgent)
 	enc.AddString("remoteIp", h.RemoteIP)
 	enc.AddString("serverIp", h.ServerIP)
@@ -42,39 +45,73 @@ func (h *gcloudHTTPRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 
 type gcloudFormatter struct {
 	projectID string
+	logSpanID bool
 }
 
 // Do not provide a default instance since we need the GCP project ID for fields like the full trace ID.
 var _ Formatter = &gcloudFormatter{}
 
+// GoogleCloudFormatterOption configures the formatter returned by NewGoogleCloudFormatter.
+type GoogleCloudFormatterOption func(*gcloudFormatter)
+
+// WithGoogleCloudSpanID controls if the span ID is logged as "spanId" (default: true).
+func WithGoogleCloudSpanID(enabled bool) GoogleCloudFormatterOption {
+	return func(f *gcloudFormatter) {
+		f.logSpanID = enabled
+	}
+}
+
+// gcloudProjectIDPattern matches valid Google Cloud project IDs: 6 to 30 lowercase letters, digits and hyphens, starting
+// with a letter and not ending with a hyphen.
+var gcloudProjectIDPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+
 // NewGoogleCloudFormatter returns a log field formatter that will log HTTP requests and traces in a Google cloud
-// compatible format.
-func NewGoogleCloudFormatter(projectID string) Formatter {
-	return &gcloudFormatter{projectID: projectID}
+// compatible format. If projectID is empty or not a valid project ID, the bare trace ID is logged instead of the full
+// trace resource name.
+func NewGoogleCloudFormatter(projectID string, opts ...GoogleCloudFormatterOption) Formatter {
+	projectID = strings.TrimSpace(projectID)
+	if !gcloudProjectIDPattern.MatchString(projectID) {
+		projectID = ""
+	}
+
+	f := &gcloudFormatter{
+		projectID: projectID,
+		logSpanID: true,
+	}
+	for _, fn := range opts {
+		fn(f)
+	}
+	return f
 }
 
 func (f *gcloudFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
-	traceID := fmt.Sprintf("projects/%s/traces/%s", f.projectID, spanCtx.TraceID().String())
-	return []zap.Field{
+	// Only use the full resource name when we know the project, "projects//traces/..." is not a valid trace.
+	traceID := spanCtx.TraceID().String()
+	if f.projectID != "" {
+		traceID = fmt.Sprintf("projects/%s/traces/%s", f.projectID, traceID)
+	}
+
+	fields := []zap.Field{
 		zap.String("trace", traceID),
-		zap.String("spanId", spanCtx.SpanID().String()),
 		zap.Bool("traceSampled", spanCtx.IsSampled()),
 	}
+	if f.logSpanID {
+		fields = append(fields, zap.String("spanId", spanCtx.SpanID().String()))
+	}
+	return fields
 }
 
 func (f *gcloudFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
-	var serverIP string
-	if localAddr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
-		serverIP = localAddr.String()
-	}
+	serverIP := serverAddress(req, res)
 
 	h := &gcloudHTTPRequest{
 		RequestMethod: req.Method,
 		RequestURL:    req.URL.Redacted(),
 		RequestSize:   strconv.FormatInt(req.ContentLength, 10),
 		Status:        res.StatusCode,
+		ResponseSize:  strconv.FormatInt(res.BytesWritten, 10),
 		UserAgent:     req.UserAgent(),
-		RemoteIP:      req.RemoteAddr,
+		RemoteIP:      clientHost(req, res),
 		ServerIP:      serverIP,
 		Referrer:      req.Referer(),
 		Latency:       strconv.FormatFloat(res.Latency.Seconds(), 'f', -1, 64) + "s",
@@ -0,0 +1,68 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestGoogleCloudFormatter(t *testing.T) {
+	t.Parallel()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	getTraceFields := func(t *testing.T, f zaphttp.Formatter) map[string]interface{} {
+		t.Helper()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		zap.New(core).Info("test", f.GetTraceFields(req, spanCtx)...)
+		return logs.All()[0].ContextMap()
+	}
+
+	t.Run("Should log the full trace resource name", func(t *testing.T) {
+		t.Parallel()
+
+		fields := getTraceFields(t, zaphttp.NewGoogleCloudFormatter("test-project"))
+		assert.Equal(t, "projects/test-project/traces/0c22384e5a0000000000000000000000", fields["trace"])
+		assert.Equal(t, "2b15000000000000", fields["spanId"])
+		assert.Equal(t, true, fields["traceSampled"])
+	})
+
+	t.Run("Should fall back to the bare trace ID without project ID", func(t *testing.T) {
+		t.Parallel()
+
+		fields := getTraceFields(t, zaphttp.NewGoogleCloudFormatter(""))
+		assert.Equal(t, "0c22384e5a0000000000000000000000", fields["trace"])
+		assert.NotContains(t, fields["trace"], "projects//")
+	})
+
+	t.Run("Should fall back to the bare trace ID for an invalid project ID", func(t *testing.T) {
+		t.Parallel()
+
+		for _, projectID := range []string{"my/project", "my project", "Test-Project", "short", "project-", "1project", strings.Repeat("a", 31)} {
+			fields := getTraceFields(t, zaphttp.NewGoogleCloudFormatter(projectID))
+			assert.Equal(t, "0c22384e5a0000000000000000000000", fields["trace"], projectID)
+		}
+	})
+
+	t.Run("Should not log the span ID when disabled", func(t *testing.T) {
+		t.Parallel()
+
+		fields := getTraceFields(t, zaphttp.NewGoogleCloudFormatter("test-project", zaphttp.WithGoogleCloudSpanID(false)))
+		assert.NotContains(t, fields, "spanId")
+		assert.Contains(t, fields, "trace")
+	})
+}
@@ -0,0 +1,35 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type logstashFormatter struct{}
+
+var _ Formatter = &logstashFormatter{}
+
+// NewLogstashFormatter returns a log field formatter that uses the classic Logstash field names that predate ECS:
+// "status", "method", "path", "bytes" (response body size) and "responsetime" (in seconds). Traces are logged as a
+// flat "trace_id" field.
+func NewLogstashFormatter() Formatter {
+	return &logstashFormatter{}
+}
+
+func (*logstashFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String("trace_id", spanCtx.TraceID().String()),
+	}
+}
+
+func (*logstashFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	return []zap.Field{
+		zap.Int("status", res.StatusCode),
+		zap.String("method", req.Method),
+		zap.String("path", req.URL.Path),
+		zap.Int64("bytes", res.BytesWritten),
+		zap.Float64("responsetime", res.Latency.Seconds()),
+	}
+}
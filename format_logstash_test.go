@@ -0,0 +1,56 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogstashFormatter(t *testing.T) {
+	t.Parallel()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithTraceFormatter(zaphttp.NewLogstashFormatter()),
+		zaphttp.WithRequestFormatter(zaphttp.NewLogstashFormatter()),
+		zaphttp.WithClock(func() time.Time { return now }),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/hello?name=world", nil)
+	req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		now = now.Add(125 * time.Millisecond)
+		_, _ = w.Write([]byte("hello world"))
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{
+		"trace_id":     "0c22384e5a0000000000000000000000",
+		"status":       int64(http.StatusOK),
+		"method":       http.MethodGet,
+		"path":         "/hello",
+		"bytes":        int64(11),
+		"responsetime": 0.125,
+	}, lines[0].ContextMap())
+}
@@ -0,0 +1,71 @@
+package zaphttp
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type lokiFormatter struct{}
+
+var _ LabelFormatter = &lokiFormatter{}
+
+// NewLokiFormatter returns a log field formatter for Grafana Loki. High-cardinality data, like the path, query and
+// trace ID, is logged as flat fields in the log line, so it can be queried using LogQL filters. GetLabels returns the
+// low-cardinality "method" and "status_class" ("2xx", "4xx", etc.) labels that are safe to use as stream labels.
+// See: https://grafana.com/docs/loki/latest/get-started/labels/bp-labels/
+func NewLokiFormatter() LabelFormatter {
+	return &lokiFormatter{}
+}
+
+func (*lokiFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String("trace_id", spanCtx.TraceID().String()),
+		zap.String("span_id", spanCtx.SpanID().String()),
+	}
+}
+
+func (*lokiFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("path", req.URL.Path),
+		zap.Int("status", res.StatusCode),
+		zap.Float64("duration_ms", float64(res.Latency.Microseconds())/1000),
+		zap.Int64("bytes", res.BytesWritten),
+		zap.String("client_ip", clientHost(req, res)),
+	}
+	if req.URL.RawQuery != "" {
+		fields = append(fields, zap.String("query", req.URL.RawQuery))
+	}
+	return fields
+}
+
+func (*lokiFormatter) GetLabels(req *http.Request, res *ResponseInfo) map[string]string {
+	return map[string]string{
+		"method":       lokiMethodLabel(req.Method),
+		"status_class": lokiStatusClass(res.StatusCode),
+	}
+}
+
+// lokiMethodLabel returns the method label for a request. Clients can send any method, so unknown methods are grouped
+// to keep the number of streams bounded.
+func lokiMethodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// lokiStatusClass returns the class of a status code, like "2xx". Status codes outside the valid range, for example 0
+// for hijacked connections, are grouped as "unknown".
+func lokiStatusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
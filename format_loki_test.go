@@ -0,0 +1,89 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLokiFormatter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should log high-cardinality data as fields", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+			SpanID:     trace.SpanID{43, 21},
+			TraceFlags: trace.FlagsSampled,
+		})
+
+		now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		formatter := zaphttp.NewLokiFormatter()
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithTraceFormatter(formatter),
+			zaphttp.WithRequestFormatter(formatter),
+			zaphttp.WithClock(func() time.Time { return now }),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/orders?id=1", nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			now = now.Add(12500 * time.Microsecond)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ok"))
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.Equal(t, map[string]interface{}{
+			"trace_id":    "0c22384e5a0000000000000000000000",
+			"span_id":     "2b15000000000000",
+			"method":      http.MethodGet,
+			"path":        "/orders",
+			"query":       "id=1",
+			"status":      int64(http.StatusOK),
+			"duration_ms": 12.5,
+			"bytes":       int64(2),
+			"client_ip":   "203.0.113.7",
+		}, lines[0].ContextMap())
+	})
+
+	t.Run("Should return low-cardinality labels", func(t *testing.T) {
+		t.Parallel()
+
+		formatter := zaphttp.NewLokiFormatter()
+
+		tests := []struct {
+			method     string
+			statusCode int
+			expected   map[string]string
+		}{
+			{http.MethodGet, http.StatusOK, map[string]string{"method": "GET", "status_class": "2xx"}},
+			{http.MethodPost, http.StatusNotFound, map[string]string{"method": "POST", "status_class": "4xx"}},
+			{"PROPFIND", http.StatusInternalServerError, map[string]string{"method": "OTHER", "status_class": "5xx"}},
+			{http.MethodGet, 0, map[string]string{"method": "GET", "status_class": "unknown"}},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(tt.method, "/orders/123", nil)
+			labels := formatter.GetLabels(req, &zaphttp.ResponseInfo{StatusCode: tt.statusCode})
+			assert.Equal(t, tt.expected, labels)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type minimalFormatter struct{}
+
+// minimalRequestFormatter only logs the status code and latency of a request. It is used by WithMinimalPreset.
+var minimalRequestFormatter RequestFormatter = &minimalFormatter{}
+
+func (*minimalFormatter) GetRequestFields(_ *http.Request, res *ResponseInfo) []zap.Field {
+	return []zap.Field{
+		zap.Int("status", res.StatusCode),
+		zap.Duration("latency", res.Latency),
+	}
+}
+
+type minimalTraceFormatter struct{}
+
+// MinimalTraceFormatter only logs the trace and span ID as top-level "trace_id" and "span_id" hex strings. This can be
+// used for log correlation with any tracing backend, combined with any request formatter.
+var MinimalTraceFormatter TraceFormatter = &minimalTraceFormatter{}
+
+func (*minimalTraceFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String("trace_id", spanCtx.TraceID().String()),
+		zap.String("span_id", spanCtx.SpanID().String()),
+	}
+}
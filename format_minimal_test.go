@@ -0,0 +1,33 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMinimalTraceFormatter(t *testing.T) {
+	t.Parallel()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	zap.New(core).Info("test", zaphttp.MinimalTraceFormatter.GetTraceFields(req, spanCtx)...)
+
+	assert.Equal(t, map[string]interface{}{
+		"trace_id": "0c22384e5a0000000000000000000000",
+		"span_id":  "2b15000000000000",
+	}, logs.All()[0].ContextMap())
+}
@@ -0,0 +1,62 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type multiFormatter struct {
+	formatters []Formatter
+}
+
+// MultiFormatter returns a formatter that concatenates the fields of all formatters, in order. This can be used to add
+// custom fields to the fields of one of the builtin formatters, for example:
+//
+//	zaphttp.MultiFormatter(zaphttp.ElasticCommonSchemaFormatter, myFormatter)
+//
+// If multiple formatters return a field with the same key, the field of the last formatter is kept. Object fields are
+// not merged, a later "http" field replaces the whole "http" object of an earlier formatter.
+func MultiFormatter(formatters ...Formatter) Formatter {
+	return &multiFormatter{formatters: formatters}
+}
+
+func (f *multiFormatter) GetTraceFields(req *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	var fields []zap.Field
+	for _, formatter := range f.formatters {
+		fields = append(fields, formatter.GetTraceFields(req, spanCtx)...)
+	}
+	return dedupeFields(fields)
+}
+
+func (f *multiFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	var fields []zap.Field
+	for _, formatter := range f.formatters {
+		fields = append(fields, formatter.GetRequestFields(req, res)...)
+	}
+	return dedupeFields(fields)
+}
+
+// dedupeFields removes fields whose key is used again by a later field. Fields after a namespace are nested in the
+// namespace, so they are left alone.
+func dedupeFields(fields []zap.Field) []zap.Field {
+	last := make(map[string]int, len(fields))
+	for i, field := range fields {
+		if field.Type == zapcore.NamespaceType {
+			break
+		}
+		last[field.Key] = i
+	}
+
+	deduped := fields[:0]
+	for i, field := range fields {
+		if j, ok := last[field.Key]; ok && i < j {
+			// A later field uses the same key.
+			continue
+		}
+		deduped = append(deduped, field)
+	}
+	return deduped
+}
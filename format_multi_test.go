@@ -0,0 +1,100 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type staticFormatter struct {
+	traceFields   []zap.Field
+	requestFields []zap.Field
+}
+
+func (f *staticFormatter) GetTraceFields(_ *http.Request, _ trace.SpanContext) []zap.Field {
+	return f.traceFields
+}
+
+func (f *staticFormatter) GetRequestFields(_ *http.Request, _ *zaphttp.ResponseInfo) []zap.Field {
+	return f.requestFields
+}
+
+func TestMultiFormatter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should combine the fields of all formatters", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		formatter := zaphttp.MultiFormatter(
+			zaphttp.ElasticCommonSchemaFormatter,
+			&staticFormatter{requestFields: []zap.Field{zap.String("team", "payments")}},
+		)
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRequestFormatter(formatter),
+		)
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Contains(t, lines[0].ContextMap(), "http")
+		assert.Equal(t, "payments", lines[0].ContextMap()["team"])
+	})
+
+	t.Run("Should keep the field of the last formatter for duplicate keys", func(t *testing.T) {
+		t.Parallel()
+
+		formatter := zaphttp.MultiFormatter(
+			&staticFormatter{
+				traceFields:   []zap.Field{zap.String("trace_id", "first"), zap.String("a", "a")},
+				requestFields: []zap.Field{zap.String("service", "first"), zap.Int("b", 1)},
+			},
+			&staticFormatter{
+				traceFields:   []zap.Field{zap.String("trace_id", "second")},
+				requestFields: []zap.Field{zap.String("service", "second")},
+			},
+		)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		assert.Equal(t, []zap.Field{
+			zap.String("a", "a"),
+			zap.String("trace_id", "second"),
+		}, formatter.GetTraceFields(req, trace.SpanContext{}))
+		assert.Equal(t, []zap.Field{
+			zap.Int("b", 1),
+			zap.String("service", "second"),
+		}, formatter.GetRequestFields(req, &zaphttp.ResponseInfo{}))
+	})
+
+	t.Run("Should not remove fields nested in a namespace", func(t *testing.T) {
+		t.Parallel()
+
+		formatter := zaphttp.MultiFormatter(
+			&staticFormatter{requestFields: []zap.Field{zap.String("name", "outer")}},
+			&staticFormatter{requestFields: []zap.Field{zap.Namespace("nested"), zap.String("name", "inner")}},
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		zap.New(core).Info("test", formatter.GetRequestFields(req, &zaphttp.ResponseInfo{})...)
+
+		assert.Equal(t, map[string]interface{}{
+			"name":   "outer",
+			"nested": map[string]interface{}{"name": "inner"},
+		}, logs.All()[0].ContextMap())
+	})
+}
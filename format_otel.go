@@ -0,0 +1,36 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type openTelemetryFormatter struct{}
+
+var _ Formatter = &openTelemetryFormatter{}
+
+// NewOpenTelemetryFormatter returns a log field formatter that logs traces as flat "trace_id", "span_id" and
+// "trace_flags" fields, as described by the OpenTelemetry specification for trace context in non-OTLP log formats.
+// See: https://opentelemetry.io/docs/specs/otel/compatibility/logging_trace_context/
+// Requests are logged with a minimal set of fields: "method", "status" and "duration_ms".
+func NewOpenTelemetryFormatter() Formatter {
+	return &openTelemetryFormatter{}
+}
+
+func (*openTelemetryFormatter) GetTraceFields(_ *http.Request, spanCtx trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String("trace_id", spanCtx.TraceID().String()),
+		zap.String("span_id", spanCtx.SpanID().String()),
+		zap.String("trace_flags", spanCtx.TraceFlags().String()),
+	}
+}
+
+func (*openTelemetryFormatter) GetRequestFields(req *http.Request, res *ResponseInfo) []zap.Field {
+	return []zap.Field{
+		zap.String("method", req.Method),
+		zap.Int("status", res.StatusCode),
+		zap.Float64("duration_ms", float64(res.Latency.Microseconds())/1000),
+	}
+}
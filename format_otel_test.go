@@ -0,0 +1,59 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestOpenTelemetryFormatter(t *testing.T) {
+	t.Parallel()
+
+	formatter := zaphttp.NewOpenTelemetryFormatter()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	t.Run("Should log flat trace fields", func(t *testing.T) {
+		t.Parallel()
+
+		spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+			SpanID:     trace.SpanID{43, 21},
+			TraceFlags: trace.FlagsSampled,
+		})
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		zap.New(core).Info("test", formatter.GetTraceFields(req, spanCtx)...)
+
+		assert.Equal(t, map[string]interface{}{
+			"trace_id":    "0c22384e5a0000000000000000000000",
+			"span_id":     "2b15000000000000",
+			"trace_flags": "01",
+		}, logs.All()[0].ContextMap())
+	})
+
+	t.Run("Should log minimal request fields", func(t *testing.T) {
+		t.Parallel()
+
+		res := &zaphttp.ResponseInfo{
+			StatusCode: http.StatusCreated,
+			Latency:    1500 * time.Microsecond,
+		}
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		zap.New(core).Info("test", formatter.GetRequestFields(req, res)...)
+
+		assert.Equal(t, map[string]interface{}{
+			"method":      http.MethodPost,
+			"status":      int64(http.StatusCreated),
+			"duration_ms": 1.5,
+		}, logs.All()[0].ContextMap())
+	})
+}
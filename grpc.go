@@ -0,0 +1,157 @@
+package zaphttp
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	grpcStatusHeader  = "Grpc-Status"
+	grpcMessageHeader = "Grpc-Message"
+
+	// grpc-gateway forwards gRPC header metadata as response headers and trailer metadata as response trailers using
+	// these prefixes.
+	grpcGatewayMetadataPrefix = "Grpc-Metadata-"
+	grpcGatewayTrailerPrefix  = "Grpc-Trailer-"
+)
+
+// gRPC status codes, see: https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcCodeOK                 = 0
+	grpcCodeCanceled           = 1
+	grpcCodeUnknown            = 2
+	grpcCodeInvalidArgument    = 3
+	grpcCodeNotFound           = 5
+	grpcCodeAlreadyExists      = 6
+	grpcCodePermissionDenied   = 7
+	grpcCodeFailedPrecondition = 9
+	grpcCodeOutOfRange         = 11
+	grpcCodeUnauthenticated    = 16
+)
+
+// grpcStatus is the status of a gRPC call as sent in the response trailers.
+type grpcStatus struct {
+	Code    int
+	Message string
+}
+
+// grpcStatusFromHeader reads the gRPC status from the response header map after the handler finished.
+// gRPC sends the status as trailers, or as headers for trailers-only responses. Returns false if the response does not
+// contain a gRPC status, a message without a status is ignored.
+func grpcStatusFromHeader(header http.Header) (*grpcStatus, bool) {
+	rawCode, hasCode := lookupHeaderOrTrailer(header, grpcStatusHeader)
+	if !hasCode {
+		return nil, false
+	}
+	rawMessage, _ := lookupHeaderOrTrailer(header, grpcMessageHeader)
+
+	// A status that is not a number is treated as unknown error by gRPC clients.
+	code, err := strconv.Atoi(rawCode)
+	if err != nil {
+		code = grpcCodeUnknown
+	}
+
+	// The message is percent encoded, see: https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+	message, err := url.PathUnescape(rawMessage)
+	if err != nil {
+		message = rawMessage
+	}
+
+	return &grpcStatus{Code: code, Message: message}, true
+}
+
+// lookupHeaderOrTrailer looks up a value that is either sent as header or as trailer. Trailers can be declared upfront
+// using the "Trailer" header or set after the header is written by using the http.TrailerPrefix.
+func lookupHeaderOrTrailer(header http.Header, name string) (string, bool) {
+	if v := header.Get(http.TrailerPrefix + name); v != "" {
+		return v, true
+	}
+	if v := header.Get(name); v != "" {
+		return v, true
+	}
+	return "", false
+}
+
+func (s *grpcStatus) Fields() []zap.Field {
+	fields := []zap.Field{
+		zap.Int("grpc.status_code", s.Code),
+	}
+	if s.Message != "" {
+		fields = append(fields, zap.String("grpc.message", s.Message))
+	}
+	return fields
+}
+
+// Level returns the minimum level a request with this gRPC status should be logged at.
+func (s *grpcStatus) Level() zapcore.Level {
+	switch s.Code {
+	case grpcCodeOK:
+		return zapcore.InfoLevel
+	case grpcCodeCanceled, grpcCodeInvalidArgument, grpcCodeNotFound, grpcCodeAlreadyExists,
+		grpcCodePermissionDenied, grpcCodeFailedPrecondition, grpcCodeOutOfRange, grpcCodeUnauthenticated:
+		// Errors caused by the client, similar to HTTP 4xx status codes.
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// responseTrailers returns the trailers set by the handler, keyed by their canonical name. Trailers are either declared
+// upfront using the "Trailer" header or set using the http.TrailerPrefix. Returns nil if there are no trailers.
+func responseTrailers(header http.Header) http.Header {
+	var trailers http.Header
+	add := func(name string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		if trailers == nil {
+			trailers = make(http.Header)
+		}
+		trailers[http.CanonicalHeaderKey(name)] = slices.Clone(values)
+	}
+
+	for _, declared := range header.Values("Trailer") {
+		for _, name := range strings.Split(declared, ",") {
+			name = strings.TrimSpace(name)
+			add(name, header.Values(name))
+		}
+	}
+	for key, values := range header {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			add(name, values)
+		}
+	}
+	return trailers
+}
+
+// grpcGatewayFields returns the gRPC status sent in the response trailers and the gRPC metadata forwarded by
+// grpc-gateway. Metadata keys that commonly contain credentials are skipped.
+func grpcGatewayFields(header, trailers http.Header) []zap.Field {
+	var fields []zap.Field
+	if status, ok := grpcStatusFromHeader(trailers); ok {
+		fields = append(fields, status.Fields()...)
+	}
+	fields = append(fields, grpcMetadataFields(header, grpcGatewayMetadataPrefix, "grpc.metadata.")...)
+	fields = append(fields, grpcMetadataFields(trailers, grpcGatewayTrailerPrefix, "grpc.trailer.")...)
+	return fields
+}
+
+// grpcMetadataFields returns a field for every header starting with prefix, sorted by name. The field name is the
+// lowercased metadata key appended to fieldPrefix.
+func grpcMetadataFields(header http.Header, prefix, fieldPrefix string) []zap.Field {
+	var fields []zap.Field
+	for _, key := range sortedKeys(header) {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" || isSensitiveHeader(name) {
+			continue
+		}
+		fields = append(fields, zap.String(fieldPrefix+strings.ToLower(name), strings.Join(header[key], ",")))
+	}
+	return fields
+}
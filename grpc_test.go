@@ -0,0 +1,175 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithGRPCStatus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should log gRPC status and message from trailers", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithGRPCStatus(),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "not%20found")
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, zapcore.WarnLevel, lines[0].Level)
+		assert.Equal(t, int64(5), lines[0].ContextMap()["grpc.status_code"])
+		assert.Equal(t, "not found", lines[0].ContextMap()["grpc.message"])
+	})
+
+	t.Run("Should raise the level for server side gRPC errors", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithGRPCStatus(),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set(http.TrailerPrefix+"Grpc-Status", "13")
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, zapcore.ErrorLevel, lines[0].Level)
+		assert.Equal(t, int64(13), lines[0].ContextMap()["grpc.status_code"])
+		assert.NotContains(t, lines[0].ContextMap(), "grpc.message")
+	})
+
+	t.Run("Should ignore a gRPC message without status", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithGRPCStatus(),
+		)
+
+		req := httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set(http.TrailerPrefix+"Grpc-Message", "something")
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+		assert.NotContains(t, lines[0].ContextMap(), "grpc.status_code")
+		assert.NotContains(t, lines[0].ContextMap(), "grpc.message")
+	})
+
+	t.Run("Should not add fields to non gRPC responses", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithGRPCStatus(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+		assert.NotContains(t, lines[0].ContextMap(), "grpc.status_code")
+	})
+}
+
+func TestWithGRPCGatewayFields(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	var trailers http.Header
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithGRPCGatewayFields(),
+		zaphttp.WithMetricsRecorder(metricsRecorderFunc(func(_ *http.Request, _ string, res *zaphttp.ResponseInfo) {
+			trailers = res.Trailers
+		})),
+	)
+
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Grpc-Metadata-Tenant", "acme")
+		w.Header().Set("Grpc-Metadata-Authorization", "Bearer secret")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code":5,"message":"user not found"}`))
+
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "user%20not%20found")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Trailer-Request-Cost", "12")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users/1", nil))
+
+	lines := logs.All()
+	require.Len(t, lines, 1)
+	fields := lines[0].ContextMap()
+	assert.Equal(t, zapcore.WarnLevel, lines[0].Level)
+	assert.Equal(t, int64(5), fields["grpc.status_code"])
+	assert.Equal(t, "user not found", fields["grpc.message"])
+	assert.Equal(t, "acme", fields["grpc.metadata.tenant"])
+	assert.Equal(t, "12", fields["grpc.trailer.request-cost"])
+	assert.NotContains(t, fields, "grpc.metadata.authorization")
+
+	assert.Equal(t, http.Header{
+		"Grpc-Status":               {"5"},
+		"Grpc-Message":              {"user%20not%20found"},
+		"Grpc-Trailer-Request-Cost": {"12"},
+	}, trailers)
+}
+
+type metricsRecorderFunc func(req *http.Request, route string, res *zaphttp.ResponseInfo)
+
+func (fn metricsRecorderFunc) RecordRequest(req *http.Request, route string, res *zaphttp.ResponseInfo) {
+	fn(req, route, res)
+}
@@ -1,101 +1,666 @@
 package zaphttp
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-type handler struct {
-	options *handlerOptions
+// Handler is a HTTP middleware that logs requests. Use Wrap to wrap a http.Handler.
+type Handler struct {
+	options          *handlerOptions
+	runtimeStats     *runtimeStatsSampler
+	latencyHistogram *latencyHistogramSet
+	logSemaphore     *logSemaphore
+	statusCounter    *statusCounter
+	sampler          *requestSampler
+	dynamicSampler   *dynamicSampler
 }
 
-func NewHandler(opts ...HandlerOption) func(next http.Handler) http.Handler {
-	h := &handler{
+// New creates a new request logging Handler. Use NewHandler if you do not need access to any of the statistics
+// collected by the Handler.
+func New(opts ...HandlerOption) *Handler {
+	h := &Handler{
 		options: buildHandlerOptions(opts...),
 	}
-	return h.Wrap
+	if h.options.runtimeStatsOnError {
+		h.runtimeStats = &runtimeStatsSampler{}
+	}
+	if h.options.latencyHistogramBuckets != nil {
+		h.latencyHistogram = newLatencyHistogramSet(h.options.latencyHistogramBuckets)
+	}
+	if h.options.countStatusCodes {
+		h.statusCounter = &statusCounter{}
+	}
+	if h.options.samplingInitial > 0 || h.options.samplingThereafter > 0 {
+		h.sampler = newRequestSampler(h.options.samplingInitial, h.options.samplingThereafter)
+	}
+	if h.options.dynamicSamplingRate > 1 {
+		h.dynamicSampler = newDynamicSampler(h.options.dynamicSamplingRate, h.options.dynamicSamplingKeys)
+	}
+	if h.options.maxConcurrentLogging > 0 {
+		h.logSemaphore = newLogSemaphore(h.options.maxConcurrentLogging, h.options.maxConcurrentLoggingWait)
+	}
+	return h
+}
+
+func NewHandler(opts ...HandlerOption) func(next http.Handler) http.Handler {
+	return New(opts...).Wrap
 }
 
-func (h *handler) Wrap(next http.Handler) http.Handler {
+func (h *Handler) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		h.handleRequest(w, req, next)
 	})
 }
 
-func (h *handler) handleRequest(w http.ResponseWriter, req *http.Request, next http.Handler) {
+func (h *Handler) handleRequest(w http.ResponseWriter, req *http.Request, next http.Handler) {
 	// Capture the request start time for logging how long a handler took.
-	start := time.Now()
+	now := h.options.clock
+	start := now()
 
 	// Build logger for this request.
 	l := h.options.perRequestLoggerFn(h.options.logger, req)
+	if len(h.options.initialFields) > 0 {
+		l = l.With(h.options.initialFields...)
+	}
+
+	// Add values from the request context, like the authenticated user or connection ID.
+	for _, f := range h.options.contextFields {
+		v := req.Context().Value(f.key)
+		switch {
+		case f.presenceOnly:
+			l = l.With(zap.Bool(f.name, v != nil))
+		case v != nil:
+			l = l.With(zap.Any(f.name, v))
+		}
+	}
+
+	for _, fn := range h.options.contextFieldFuncs {
+		l = l.With(fn(req.Context())...)
+	}
+
+	// Promote request headers to fields, like a tenant ID.
+	if len(h.options.requestHeaderFields) > 0 {
+		l = l.With(headerFields(req.Header, h.options.requestHeaderFields)...)
+	}
+
+	// Add statistics about the client connection, this also counts the request on its connection.
+	if h.options.connectionStats {
+		l = l.With(connectionStatsFields(req, start)...)
+	}
+
+	// Span of the request, if tracing is configured.
+	var currentSpan trace.SpanContext
+	if !h.options.disableTracing {
+		currentSpan = trace.SpanContextFromContext(req.Context())
+		if !currentSpan.IsValid() && h.options.parseTraceparent {
+			// Only used for logging, the span context is not added to the request context.
+			currentSpan = traceparentFromHeader(req.Header)
+		}
+	}
+
+	// Use the request ID of the client or generate a new one, echo it so the client can report it.
+	var requestID string
+	if name := h.options.requestIDHeader; name != "" {
+		requestID = strings.TrimSpace(req.Header.Get(name))
+		if !isValidRequestID(requestID) {
+			// Do not log or echo IDs that are too long or contain characters that could forge log lines.
+			requestID = ""
+		}
+		if requestID == "" && h.options.requestIDFromTrace && currentSpan.IsValid() {
+			requestID = currentSpan.TraceID().String()
+		}
+		if requestID == "" {
+			requestID = h.options.requestIDGenerator()
+		}
+		w.Header().Set(name, requestID)
+		l = l.With(zap.String("request_id", requestID))
+	}
+
+	if h.options.eventID {
+		// Unlike the request ID, the event ID is never supplied by the client, so it is unique for every request.
+		l = l.With(zap.String("event_id", NewRequestID()))
+	}
+
+	// Mark replayed traffic so it can be distinguished from live traffic.
+	if h.options.replayIDHeader != "" {
+		if replayID := req.Header.Get(h.options.replayIDHeader); replayID != "" {
+			l = l.With(zap.String("replay_id", replayID), zap.Bool("is_replay", true))
+		}
+	}
+
+	// Add propagated OpenTelemetry baggage as labels.
+	if h.options.baggageLabels {
+		if field, ok := baggageLabelsField(baggage.FromContext(req.Context())); ok {
+			l = l.With(field)
+		}
+	}
 
 	// Add trace information if tracing is configured.
-	currentSpan := trace.SpanContextFromContext(req.Context())
 	if currentSpan.IsValid() {
+		if name := h.options.traceIDResponseHeader; name != "" {
+			// Set before the handler runs, so it is sent with the response header.
+			w.Header().Set(name, currentSpan.TraceID().String())
+		}
 		fields := h.options.traceFormatter.GetTraceFields(req, currentSpan)
 		l = l.With(fields...)
 	}
 
 	// Inject logger in the request context.
-	req = injectLoggerInContext(req, l)
+	state := &requestState{logger: l, requestID: requestID}
+	req = injectRequestStateInContext(req, state)
+	loggerBuildTime := now().Sub(start)
+
+	// Information about the request that is passed to the formatter for every log line.
+	clientHost, clientPort := h.options.remoteAddrParser(req.RemoteAddr)
+	base := ResponseInfo{
+		Start:          start,
+		ClientHost:     clientHost,
+		ClientPort:     clientPort,
+		RequestHeaders: captureHeaders(req.Header, h.options.requestHeaders),
+	}
+	if h.options.serverAddressFn != nil {
+		base.ServerAddress = h.options.serverAddressFn(req)
+	}
+
+	// Capture the start of the request body while the handler reads it.
+	preview := captureRequestBody(req, h.options.bodyPreviewMaxBytes, h.options.bodyPreviewContentTypes)
+
+	// Capture the request body while the handler reads it.
+	capture := captureRequestBody(req, h.options.requestBodyMaxBytes, h.options.requestBodyContentTypes)
 
 	// Wrap http.ResponseWriter so we can extract the status code from the response.
-	sr := &statusRecorder{writer: w}
+	sr := &statusRecorder{
+		writer:             w,
+		now:                now,
+		captureHeaders:     h.options.responseHeaders,
+		headerFieldNames:   h.options.responseHeaderFields,
+		countLinesFor:      h.options.lineCountContentTypes,
+		captureTrailers:    h.options.logGRPCGateway,
+		errorBodyMaxBytes:  h.options.errorBodyMaxBytes,
+		errorBodyMinStatus: h.options.errorBodyMinStatus,
+	}
+	state.recorder = sr
+	if h.options.logHijack {
+		sr.onHijack = func(conn net.Conn) {
+			h.logHijack(l, req, conn, now().Sub(start))
+		}
+	}
+	if fn := h.options.onFirstWrite; fn != nil {
+		sr.onFirstWrite = func() {
+			fn(req, now().Sub(start))
+		}
+	}
 
 	var completed bool
 	defer func() {
 		if !completed {
 			// next.ServeHTTP did not complete normally. We either panicked or runtime.Goexit() was called.
-			// Do not recover the panic since this would mess with the stacktrace, just log it.
-			h.logRequest(l, zapcore.ErrorLevel, "HTTP request panicked", req, &ResponseInfo{
-				StatusCode:  sr.StatusCode,
-				ContentType: sr.ContentType,
-				Start:       start,
-				Latency:     time.Since(start),
-			})
+			// By default the panic is not recovered since this would mess with the stacktrace, just log it.
+			var recovered any
+			if h.options.recoverPanics {
+				// recover must be called directly by the deferred function.
+				recovered = recover()
+			}
+			if recovered != nil && recovered != http.ErrAbortHandler && !sr.writeHeaderCalled && !sr.hijacked {
+				// Write the response before logging, so the logged and recorded status is the one the client gets.
+				h.writePanicResponse(sr)
+			}
+
+			res := sr.ResponseInfo(base)
+			res.RequestBody, res.RequestBodyTruncated = capture.Body()
+			res.Panicked = true
+			if h.options.panicErrorFields {
+				// The panic value can only be read by recovering, which is only done if WithRecover is used.
+				res.PanicValue = recovered
+				// The deferred function runs on top of the panicking frames, so they are part of the stack.
+				res.PanicStack = debug.Stack()
+			}
+			fields := h.completionFields(req, sr, res, preview)
+			if h.options.lateTraceFields && !currentSpan.IsValid() {
+				fields = append(fields, h.lateTraceFields(req, state)...)
+			}
+			if h.options.panicStackTrace {
+				fields = append(fields, panicStackFields()...)
+			}
+			if recovered != nil && recovered != http.ErrAbortHandler {
+				// The panic was recovered and the client got a response, count it like a completed request.
+				h.recordCompleted(req, res)
+			}
+			if h.options.metricsRecorder != nil {
+				h.options.metricsRecorder.RecordRequest(req, h.options.routePatternFn(req), res)
+			}
+			h.logRequest(l, h.options.panicLevel, h.options.messages.Panicked, req, res, fields...)
+
+			if recovered == http.ErrAbortHandler {
+				// The handler explicitly wants the server to abort the response.
+				panic(recovered)
+			}
 		}
 	}()
 
-	h.logRequest(l, zapcore.DebugLevel, "Received HTTP request", req, &ResponseInfo{Start: start})
+	if h.options.logStart {
+		h.logRequest(l, h.options.startLevel, h.options.messages.Start, req, &base,
+			// Time spent building the per-request logger, useful to find expensive per-request logger functions.
+			zap.Int64("logger_build_us", loggerBuildTime.Microseconds()),
+		)
+	}
+
+	// Custom wrappers wrap the status recorder, so everything they write still passes through it.
+	var rw http.ResponseWriter = sr
+	if h.options.responseWriterWrapper != nil {
+		rw = h.options.responseWriterWrapper(sr)
+	}
 
-	next.ServeHTTP(sr, req)
+	next.ServeHTTP(rw, req)
 	completed = true
+	sr.finish()
 
 	// Request handler finished, log the result.
-	res := &ResponseInfo{
-		StatusCode:  sr.StatusCode,
-		ContentType: sr.ContentType,
-		Start:       start,
-		Latency:     time.Since(start),
+	res := sr.ResponseInfo(base)
+	res.RequestBody, res.RequestBodyTruncated = capture.Body()
+
+	h.recordCompleted(req, res)
+	if h.options.metricsRecorder != nil {
+		h.options.metricsRecorder.RecordRequest(req, h.options.routePatternFn(req), res)
 	}
 
-	if sr.StatusCode <= 399 {
-		// Everything OK!
-		h.logRequest(l, zapcore.InfoLevel, "HTTP request finished", req, res)
+	if h.options.logHijack && res.Hijacked {
+		// The hijacked log line replaces the finished log line, the status of a hijacked request is meaningless.
 		return
 	}
 
-	if sr.StatusCode <= 499 {
+	fields := h.completionFields(req, sr, res, preview)
+	if h.options.lateTraceFields && !currentSpan.IsValid() {
+		fields = append(fields, h.lateTraceFields(req, state)...)
+	}
+
+	var msg string
+	switch {
+	case sr.StatusCode <= 399:
+		// Everything OK!
+		msg = h.options.messages.Finished
+	case sr.StatusCode <= 499:
 		// Client side error.
-		h.logRequest(l, zapcore.WarnLevel, "HTTP request failed due to a client error", req, res)
+		msg = h.options.messages.ClientError
+	default:
+		// Other unknown code, likely a server error.
+		msg = h.options.messages.ServerError
+	}
+
+	level := h.options.levelFn(sr.StatusCode)
+	if h.options.fixedCompletionLevel != nil {
+		// Keep the status-based message, only the level is fixed.
+		level = *h.options.fixedCompletionLevel
+	}
+
+	if h.options.slowRequestThreshold > 0 && res.StatusCode < http.StatusBadRequest &&
+		res.Latency > h.options.slowRequestThreshold {
+		// Only raise the level, a level function may already log this status at a higher level.
+		fields = append(fields, zap.Bool("slow", true))
+		level = max(level, h.options.slowRequestLevel)
+	}
+
+	if h.options.deadlineExceededLevel != nil && errors.Is(req.Context().Err(), context.DeadlineExceeded) {
+		// The request ran out of time, this can be expected under load.
+		fields = append(fields, zap.Bool("deadline_exceeded", true))
+		level = *h.options.deadlineExceededLevel
+	}
+
+	if h.options.logClientDisconnected && isClientDisconnect(req.Context().Err()) {
+		fields = append(fields, zap.Bool("client_disconnected", true))
+		if h.options.clientDisconnectLevel != nil {
+			level = min(level, *h.options.clientDisconnectLevel)
+		}
+	}
+
+	if fn := h.options.successErrorClassifier; fn != nil && res.StatusCode < http.StatusMultipleChoices && fn(req, res) {
+		// The handler responded with a success status code, but the response contains an error.
+		fields = append(fields, zap.Bool("logical_error", true))
+		level = max(level, zapcore.ErrorLevel)
+	}
+
+	if h.options.logGRPCStatus {
+		// gRPC responses always use HTTP status 200, the actual result is sent in the grpc-status trailer.
+		if status, ok := grpcStatusFromHeader(sr.Header()); ok {
+			fields = append(fields, status.Fields()...)
+			level = max(level, status.Level())
+		}
+	}
+
+	// Internal traffic can be logged at a lower level to reduce log volume. Any client can send the header, so warnings
+	// and errors are never downgraded.
+	for _, d := range h.options.headerLevelDowngrades {
+		if level < zapcore.WarnLevel && req.Header.Get(d.header) == d.value {
+			level = min(level, d.level)
+		}
+	}
+
+	// Handlers can request a higher level for their own request, this overrides the downgrades above.
+	if elevated, ok := state.elevation(); ok {
+		level = max(level, elevated)
+	}
+
+	if h.options.statusClassSampling != nil && !sampleStatusClass(h.options.statusClassSampling, res.StatusCode) {
+		h.logSkipped(l, skipReasonStatusClassSampling, level, msg)
+		return
+	}
+
+	// Only sample successful requests, warnings and errors are always logged.
+	if h.sampler != nil && level == zapcore.InfoLevel &&
+		!h.sampler.Sample(h.options.routePatternFn(req), h.options.clock()) {
+		h.logSkipped(l, skipReasonSampling, level, msg)
+		return
+	}
+
+	if h.dynamicSampler != nil && level <= zapcore.InfoLevel && res.StatusCode < http.StatusBadRequest &&
+		!h.dynamicSampler.Sample(dynamicSamplingKey(h.dynamicSamplingKey(req), res.StatusCode)) {
+		h.logSkipped(l, skipReasonDynamicSampling, level, msg)
 		return
 	}
 
-	// Other unknown code, likely a server error.
-	h.logRequest(l, zapcore.ErrorLevel, "HTTP request failed", req, res)
+	h.logRequest(l, level, msg, req, res, fields...)
 }
 
-func (h *handler) logRequest(l *zap.Logger, level zapcore.Level, msg string, req *http.Request, res *ResponseInfo) {
-	if shouldLog := h.options.perRequestFilterFn(req, level); !shouldLog {
+// dynamicSamplingKey returns the key used to group identical requests for dynamic sampling.
+func (h *Handler) dynamicSamplingKey(req *http.Request) string {
+	if h.options.dynamicSamplingKeyFn != nil {
+		return h.options.dynamicSamplingKeyFn(req)
+	}
+	return req.Method + " " + h.options.routePatternFn(req)
+}
+
+// isClientDisconnect reports whether err, the error of the request context, indicates the client stopped waiting for
+// the response. net/http cancels the request context when the client closes the connection. An exceeded deadline comes
+// from a server-side timeout instead, see WithDeadlineExceededLevel.
+func isClientDisconnect(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// lateTraceFields returns the trace fields of a span that was started after the request was received. The span is
+// read from the request context again, or recorded by the handler using RecordSpan. The fields are only added to the
+// completion log line, the logger of the request is not changed.
+func (h *Handler) lateTraceFields(req *http.Request, state *requestState) []zap.Field {
+	if h.options.disableTracing {
+		return nil
+	}
+
+	spanCtx := trace.SpanContextFromContext(req.Context())
+	if recorded := state.span.Load(); recorded != nil {
+		spanCtx = *recorded
+	}
+	if !spanCtx.IsValid() {
+		return nil
+	}
+	return h.options.traceFormatter.GetTraceFields(req, spanCtx)
+}
+
+// recordCompleted adds a request the client got a response for to the latency histograms and status counts.
+func (h *Handler) recordCompleted(req *http.Request, res *ResponseInfo) {
+	if h.latencyHistogram != nil {
+		h.latencyHistogram.Observe(h.options.routePatternFn(req), res.Latency)
+	}
+	if h.statusCounter != nil {
+		h.statusCounter.Increment(res.StatusCode)
+	}
+}
+
+// logHijack logs that the connection of a request is hijacked, this is logged when it happens since the handler of a
+// hijacked connection (for example a WebSocket) may run for a long time.
+func (h *Handler) logHijack(l *zap.Logger, req *http.Request, conn net.Conn, elapsed time.Duration) {
+	msg := h.options.messages.Hijacked
+	if h.skipRequest(l, zapcore.InfoLevel, msg, req) {
+		return
+	}
+
+	if ce := l.Check(zapcore.InfoLevel, msg); ce != nil {
+		h.writeRequestEntry(ce, []zap.Field{
+			zap.Duration("hijack.elapsed", elapsed),
+			zap.Stringer("connection.remote_address", conn.RemoteAddr()),
+			zap.Stringer("connection.local_address", conn.LocalAddr()),
+		})
+	}
+}
+
+// writePanicResponse writes the response for a recovered panic, the handler did not write a response yet.
+func (h *Handler) writePanicResponse(w http.ResponseWriter) {
+	if h.options.panicResponseBody == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", h.options.panicResponseContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(h.options.panicResponseBody)))
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write(h.options.panicResponseBody)
+}
+
+// completionFields returns the fields that are only added to the log line written once the handler is done.
+func (h *Handler) completionFields(
+	req *http.Request,
+	sr *statusRecorder,
+	res *ResponseInfo,
+	preview *bodyCapture,
+) []zap.Field {
+	var fields []zap.Field
+
+	if res.Hijacked {
+		fields = append(fields, zap.Bool("hijacked", true))
+	}
+
+	if h.options.logImplicitStatus && sr.ImplicitStatus {
+		fields = append(fields, zap.Bool("implicit_status", true))
+	}
+
+	if h.options.detectStatusOverride && sr.StatusOverridden() {
+		fields = append(fields,
+			zap.Bool("status_overridden", true),
+			zap.Int("status.original_code", sr.StatusCode),
+			zap.Int("status.override_code", sr.OverrideStatusCode),
+		)
+	}
+
+	if route := h.options.routePatternFn(req); route != "" {
+		fields = append(fields, zap.String("http.route", route))
+	}
+
+	if res.ContentLanguage != "" {
+		fields = append(fields, zap.String("response.language", res.ContentLanguage))
+	}
+
+	if h.options.logNoRoute && requestPattern(req) == "" && res.StatusCode == http.StatusNotFound {
+		// http.ServeMux sets the pattern on the request when a route matched.
+		fields = append(fields, zap.Bool("no_route_matched", true))
+	}
+
+	if h.options.detectProtocolDowngrade && isProtocolDowngrade(req) {
+		fields = append(fields,
+			zap.Bool("protocol_downgrade", true),
+			zap.String("tls.next_protocol", req.TLS.NegotiatedProtocol),
+		)
+	}
+
+	if h.options.logTimeoutBudget {
+		if deadline, ok := req.Context().Deadline(); ok {
+			if budget := deadline.Sub(res.Start); budget > 0 {
+				fields = append(fields,
+					zap.Duration("budget", budget),
+					zap.Float64("budget_used_fraction", float64(res.Latency)/float64(budget)),
+				)
+			}
+		}
+	}
+
+	if h.options.logHeaderSize {
+		size := headerSize(req.Header)
+		fields = append(fields, zap.Int("request.headers_bytes", size))
+		if h.options.oversizedHeaderThreshold > 0 && size > h.options.oversizedHeaderThreshold {
+			fields = append(fields, zap.Bool("oversized_headers", true))
+		}
+	}
+
+	if h.options.spanStatusFn != nil {
+		fields = append(fields, spanStatusFields(req, h.options.spanStatusFn)...)
+	}
+
+	if h.options.logGRPCGateway {
+		fields = append(fields, grpcGatewayFields(sr.Header(), res.Trailers)...)
+	}
+
+	if h.options.logAuthority {
+		// For HTTP/2 and HTTP/3 requests, net/http stores the :authority pseudo-header in req.Host.
+		fields = append(fields, zap.String("http.authority", req.Host))
+		if forwardedHost := req.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+			fields = append(fields, zap.String("http.forwarded_host", forwardedHost))
+		}
+	}
+
+	fields = append(fields, sr.HeaderFields...)
+
+	if sr.captureBody && len(sr.Body) > 0 {
+		fields = append(fields,
+			zap.ByteString("response.body", sr.Body),
+			zap.Bool("response.body_truncated", sr.BodyTruncated),
+		)
+	}
+
+	if sr.countLines {
+		fields = append(fields, zap.Int64("response.line_count", sr.LineCount))
+	}
+
+	if name := h.options.upstreamLatencyHeader; name != "" {
+		// Proxies copy the header from the upstream response, fall back to the request for proxies in front of us.
+		value := sr.Header().Get(name)
+		if value == "" {
+			value = req.Header.Get(name)
+		}
+		if latency, ok := parseUpstreamLatency(value); ok {
+			fields = append(fields, zap.Float64("upstream_latency_ms", float64(latency.Microseconds())/1000))
+		}
+	}
+
+	if h.options.detectCORSPreflight {
+		fields = append(fields, corsPreflightFields(req)...)
+	}
+
+	if h.options.detectUpgrade {
+		fields = append(fields, upgradeFields(req)...)
+	}
+
+	if h.options.normalizePath {
+		normalized := normalizePath(req.URL.Path)
+		fields = append(fields, zap.String("url.normalized_path", normalized))
+		if normalized != req.URL.Path {
+			fields = append(fields, zap.Bool("path_anomaly", true))
+		}
+	}
+
+	isError := res.Panicked || res.StatusCode >= http.StatusBadRequest
+	if data, truncated := preview.Body(); data != nil && (isError || !h.options.bodyPreviewOnErrorOnly) {
+		fields = append(fields,
+			zap.ByteString("request.body_preview", data),
+			zap.Bool("request.body_preview_truncated", truncated),
+		)
+	}
+
+	return fields
+}
+
+func (h *Handler) logRequest(
+	l *zap.Logger,
+	level zapcore.Level,
+	msg string,
+	req *http.Request,
+	res *ResponseInfo,
+	extraFields ...zap.Field,
+) {
+	if h.skipRequest(l, level, msg, req) {
 		return
 	}
 
 	if ce := l.Check(level, msg); ce != nil {
-		fields := h.options.requestFormatter.GetRequestFields(req, res)
-		ce.Write(fields...)
+		fields := h.options.requestFormatter.GetRequestFields(redactRequestQuery(req, h.options.redactedQueryParams), res)
+		if h.runtimeStats != nil && (res.Panicked || res.StatusCode >= http.StatusInternalServerError) {
+			// Only read runtime stats once we know the log entry is actually written.
+			fields = append(fields, h.runtimeStats.Fields()...)
+		}
+		fields = append(fields, extraFields...)
+		h.writeRequestEntry(ce, fields)
+	}
+}
+
+// skipRequest returns true if no line should be logged for the request because of the path patterns configured using
+// WithOnlyLogPaths or the per-request filter.
+func (h *Handler) skipRequest(l *zap.Logger, level zapcore.Level, msg string, req *http.Request) bool {
+	if h.options.onlyLogPaths != nil && !h.options.onlyLogPaths.Match(req.URL.Path) {
+		h.logSkipped(l, skipReasonPathNotIncluded, level, msg)
+		return true
+	}
+
+	if shouldLog := h.options.perRequestFilterFn(req, level); !shouldLog {
+		h.logSkipped(l, skipReasonFilter, level, msg)
+		return true
+	}
+	return false
+}
+
+// writeRequestEntry redacts, sorts and namespaces the fields of a request line and writes it, if the logging semaphore
+// has room for it.
+func (h *Handler) writeRequestEntry(ce *zapcore.CheckedEntry, fields []zap.Field) {
+	if h.options.fieldRedactor != nil {
+		fields = redactFields(fields, h.options.fieldRedactor)
+	}
+	if h.options.sortFields {
+		slices.SortStableFunc(fields, func(a, b zap.Field) int {
+			return strings.Compare(a.Key, b.Key)
+		})
+	}
+	if h.options.fieldNamespace != "" {
+		// All fields after the namespace field are nested in it.
+		fields = slices.Insert(fields, 0, zap.Namespace(h.options.fieldNamespace))
+	}
+
+	if h.logSemaphore != nil {
+		if !h.logSemaphore.Acquire() {
+			return
+		}
+		defer h.logSemaphore.Release()
+	}
+	ce.Write(fields...)
+}
+
+// Reasons logged by logSkipped.
+const (
+	skipReasonFilter              = "per_request_filter"
+	skipReasonStatusClassSampling = "status_class_sampling"
+	skipReasonPathNotIncluded     = "path_not_included"
+	skipReasonSampling            = "sampling"
+	skipReasonDynamicSampling     = "dynamic_sampling"
+)
+
+// logSkipped logs why a log line for a request was not written, only if WithLogSkipReasons is used.
+func (h *Handler) logSkipped(l *zap.Logger, reason string, level zapcore.Level, msg string) {
+	if !h.options.logSkipReasons {
+		return
+	}
+
+	if ce := l.Check(zapcore.DebugLevel, "HTTP request logging skipped"); ce != nil {
+		ce.Write(
+			zap.String("skip.reason", reason),
+			zap.Stringer("skip.level", level),
+			zap.String("skip.message", msg),
+		)
 	}
 }
@@ -0,0 +1,84 @@
+package zaphttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newBenchmarkLogger returns a logger that encodes log lines as JSON and discards them, so encoding costs are included.
+func newBenchmarkLogger() *zap.Logger {
+	return zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(io.Discard),
+		zapcore.InfoLevel,
+	))
+}
+
+func BenchmarkHandler(b *testing.B) {
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	benchmarks := []struct {
+		name string
+		opts []zaphttp.HandlerOption
+	}{
+		{
+			name: "Tracing",
+		},
+		{
+			name: "Noop trace formatter",
+			opts: []zaphttp.HandlerOption{zaphttp.WithTraceFormatter(zaphttp.NoopFormatter)},
+		},
+		{
+			name: "Without tracing",
+			opts: []zaphttp.HandlerOption{zaphttp.WithoutTracing()},
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			opts := append([]zaphttp.HandlerOption{zaphttp.WithLogger(newBenchmarkLogger())}, bm.opts...)
+			handler := zaphttp.NewHandler(opts...)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+			rec := httptest.NewRecorder()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				handler.ServeHTTP(rec, req)
+			}
+		})
+	}
+}
+
+func BenchmarkElasticCommonSchemaFormatter(b *testing.B) {
+	formatter := zaphttp.NewElasticCommonSchemaFormatter(zaphttp.WithECSStructuredLatency())
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/orders?id=1", nil)
+	req.Header.Set("User-Agent", "benchmark")
+	req.Header.Set("Content-Type", "application/json")
+	res := &zaphttp.ResponseInfo{
+		StatusCode:  http.StatusCreated,
+		ContentType: "application/json",
+		ClientHost:  "203.0.113.7",
+		ClientPort:  51234,
+	}
+
+	b.ReportAllocs()
+	for range b.N {
+		_ = formatter.GetRequestFields(req, res)
+	}
+}
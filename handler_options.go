@@ -1,12 +1,18 @@
 package zaphttp
 
 import (
+	"context"
+	"maps"
 	"net/http"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// FirstWriteFunc is called when a handler starts writing the response. Elapsed is the time since the request was received.
+type FirstWriteFunc func(req *http.Request, elapsed time.Duration)
+
 type PerRequestLoggerFunc func(parent *zap.Logger, req *http.Request) *zap.Logger
 
 func DefaultPerRequestLoggerFunc(parent *zap.Logger, _ *http.Request) *zap.Logger {
@@ -21,12 +27,141 @@ func DefaultPerRequestFilterFunc(_ *http.Request, _ zapcore.Level) bool {
 	return true
 }
 
+// LevelFunc returns the level used to log a completed request with the given status code.
+type LevelFunc func(statusCode int) zapcore.Level
+
+// DefaultLevelFunc logs 1xx, 2xx and 3xx status codes at info, 4xx status codes at warn and all others at error.
+func DefaultLevelFunc(statusCode int) zapcore.Level {
+	switch {
+	case statusCode <= 399:
+		return zapcore.InfoLevel
+	case statusCode <= 499:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
 type handlerOptions struct {
 	logger             *zap.Logger
 	perRequestLoggerFn PerRequestLoggerFunc
+	initialFields      []zap.Field
 	perRequestFilterFn PerRequestFilterFunc
-	traceFormatter     TraceFormatter
-	requestFormatter   RequestFormatter
+	routePatternFn     RoutePatternFunc
+	onlyLogPaths       *pathMatcher
+	parseTraceparent   bool
+	disableTracing     bool
+	lateTraceFields    bool
+	logHijack          bool
+	sortFields         bool
+	fieldNamespace     string
+	eventID            bool
+	logAuthority       bool
+	// lineCountContentTypes matches the content types of responses whose lines are counted, nil if disabled.
+	lineCountContentTypes contentTypeMatcher
+	// traceIDResponseHeader is the name of the response header the trace ID is written to, empty if disabled.
+	traceIDResponseHeader string
+	traceFormatter        TraceFormatter
+	requestFormatter      RequestFormatter
+	logStart              bool
+	startLevel            zapcore.Level
+	clock                 func() time.Time
+	remoteAddrParser      RemoteAddrParserFunc
+	serverAddressFn       func(req *http.Request) string
+	panicLevel            zapcore.Level
+	panicStackTrace       bool
+	recoverPanics         bool
+	panicErrorFields      bool
+
+	panicResponseContentType string
+	panicResponseBody        []byte
+	messages                 Messages
+
+	levelFn               LevelFunc
+	spanStatusFn          SpanStatusFunc
+	fixedCompletionLevel  *zapcore.Level
+	deadlineExceededLevel *zapcore.Level
+	logClientDisconnected bool
+	clientDisconnectLevel *zapcore.Level
+	slowRequestThreshold  time.Duration
+	slowRequestLevel      zapcore.Level
+
+	logHeaderSize            bool
+	oversizedHeaderThreshold int
+
+	runtimeStatsOnError bool
+	contextFields       []contextField
+	contextFieldFuncs   []func(ctx context.Context) []zap.Field
+	connectionStats     bool
+	baggageLabels       bool
+	replayIDHeader      string
+	requestIDHeader     string
+	requestIDGenerator  func() string
+	requestIDFromTrace  bool
+
+	latencyHistogramBuckets []time.Duration
+	countStatusCodes        bool
+	metricsRecorder         MetricsRecorder
+
+	bodyPreviewMaxBytes     int
+	bodyPreviewOnErrorOnly  bool
+	bodyPreviewContentTypes contentTypeMatcher
+	requestBodyMaxBytes     int
+	requestBodyContentTypes contentTypeMatcher
+	errorBodyMaxBytes       int
+	errorBodyMinStatus      int
+
+	logNoRoute    bool
+	logGRPCStatus bool
+	// logGRPCGateway logs the gRPC status and metadata forwarded by grpc-gateway.
+	logGRPCGateway bool
+	normalizePath  bool
+
+	detectProtocolDowngrade bool
+	logSkipReasons          bool
+	logTimeoutBudget        bool
+	detectCORSPreflight     bool
+	detectUpgrade           bool
+	detectStatusOverride    bool
+	logImplicitStatus       bool
+	upstreamLatencyHeader   string
+	requestHeaders          []string
+	requestHeaderFields     map[string]string
+	responseHeaders         []string
+	responseHeaderFields    map[string]string
+	redactedQueryParams     map[string]struct{}
+
+	maxConcurrentLogging     int
+	maxConcurrentLoggingWait time.Duration
+
+	onFirstWrite  FirstWriteFunc
+	fieldRedactor FieldRedactorFunc
+
+	responseWriterWrapper func(http.ResponseWriter) http.ResponseWriter
+	headerLevelDowngrades []headerLevelDowngrade
+	statusClassSampling   map[int]float64
+	samplingInitial       int
+	dynamicSamplingKeyFn  DynamicSamplingKeyFunc
+	dynamicSamplingRate   int
+	dynamicSamplingKeys   int
+	samplingThereafter    int
+
+	successErrorClassifier func(req *http.Request, res *ResponseInfo) bool
+}
+
+// headerLevelDowngrade lowers the completion log level of requests with a specific header value.
+type headerLevelDowngrade struct {
+	header string
+	value  string
+	level  zapcore.Level
+}
+
+// contextField maps a context value to a log field.
+type contextField struct {
+	key  any
+	name string
+	// presenceOnly logs if the value is present instead of the value itself.
+	presenceOnly bool
 }
 
 func defaultHandlerOptions() *handlerOptions {
@@ -34,8 +169,18 @@ func defaultHandlerOptions() *handlerOptions {
 		logger:             zap.L(),
 		perRequestLoggerFn: DefaultPerRequestLoggerFunc,
 		perRequestFilterFn: DefaultPerRequestFilterFunc,
+		routePatternFn:     DefaultRoutePatternFunc,
 		traceFormatter:     DefaultFormatter,
 		requestFormatter:   DefaultFormatter,
+		logStart:           true,
+		startLevel:         zapcore.DebugLevel,
+		clock:              time.Now,
+		messages:           DefaultMessages,
+		levelFn:            DefaultLevelFunc,
+		remoteAddrParser:   DefaultRemoteAddrParser,
+		panicLevel:         zapcore.ErrorLevel,
+
+		bodyPreviewContentTypes: isTextContentType,
 	}
 }
 
@@ -61,6 +206,15 @@ func WithPerRequestLogger(fn PerRequestLoggerFunc) HandlerOption {
 	}
 }
 
+// WithInitialFields adds fields to the logger of every request, for example the name and version of the service. The
+// fields are added after the per-request logger function runs, so they are included on the start and finish log lines.
+// Multiple calls add to the fields.
+func WithInitialFields(fields ...zap.Field) HandlerOption {
+	return func(options *handlerOptions) {
+		options.initialFields = append(options.initialFields, fields...)
+	}
+}
+
 // WithPerRequestFilter is an option that allows filtering out log messages for specific requests.
 func WithPerRequestFilter(fn PerRequestFilterFunc) HandlerOption {
 	return func(options *handlerOptions) {
@@ -68,6 +222,19 @@ func WithPerRequestFilter(fn PerRequestFilterFunc) HandlerOption {
 	}
 }
 
+// WithRoutePatternFunc sets the function used to get the route pattern that matched the request, it is logged as
+// "http.route" and used to group requests for metrics and sampling. The default uses the pattern of http.ServeMux, use
+// this option for third-party routers. For example, with chi:
+//
+//	zaphttp.WithRoutePatternFunc(func(req *http.Request) string {
+//		return chi.RouteContext(req.Context()).RoutePattern()
+//	})
+func WithRoutePatternFunc(fn RoutePatternFunc) HandlerOption {
+	return func(options *handlerOptions) {
+		options.routePatternFn = fn
+	}
+}
+
 func WithTraceFormatter(f TraceFormatter) HandlerOption {
 	return func(options *handlerOptions) {
 		options.traceFormatter = f
@@ -79,3 +246,776 @@ func WithRequestFormatter(f RequestFormatter) HandlerOption {
 		options.requestFormatter = f
 	}
 }
+
+// WithOnlyLogPaths only logs requests whose path matches one of the patterns, all other requests are silenced.
+// Patterns containing "*", "?" or "[" are glob patterns matched using path.Match, so "*" does not match "/". All other
+// patterns match paths starting with the pattern, for example "/api/" matches every path below /api.
+// This composes with WithPerRequestFilter: a request is only logged if it matches a pattern and the filter allows it.
+func WithOnlyLogPaths(patterns ...string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.onlyLogPaths = newPathMatcher(patterns)
+	}
+}
+
+// WithoutTracing skips looking up the span of the request entirely, for services that do not use tracing. No trace
+// fields are logged and options that depend on the span, like WithRequestIDFromTrace and WithTraceIDResponseHeader,
+// have no effect. This is cheaper than using NoopFormatter as trace formatter.
+func WithoutTracing() HandlerOption {
+	return func(options *handlerOptions) {
+		options.disableTracing = true
+	}
+}
+
+// WithLateTraceFields adds the trace fields to the log line written when the request completes, if there was no
+// span yet when the request was received. This supports applications that start their span inside the handler, use
+// RecordSpan or CaptureSpan to make the span known to the zaphttp handler. The fields are only added to the completion
+// log line, not to the logger of the request.
+func WithLateTraceFields() HandlerOption {
+	return func(options *handlerOptions) {
+		options.lateTraceFields = true
+	}
+}
+
+// WithParseTraceparent parses the W3C traceparent header of the request when the request context does not contain a
+// valid span, for example because the OpenTelemetry middleware is not used. The parsed trace is only used for the
+// trace fields in the logs, it is not added to the request context. Malformed headers are ignored.
+func WithParseTraceparent(enabled bool) HandlerOption {
+	return func(options *handlerOptions) {
+		options.parseTraceparent = enabled
+	}
+}
+
+// WithTraceIDResponseHeader writes the trace ID of the request to the response header with the given name (for
+// example X-Trace-ID), so it can be copied from the network tab of the browser to search the logs. The header is only
+// set if the request has a valid span.
+func WithTraceIDResponseHeader(name string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.traceIDResponseHeader = name
+	}
+}
+
+// WithLogRequestEvenOnEarlyHijack logs a dedicated "HTTP request hijacked" line at info level as soon as the handler
+// hijacks the connection, for example to upgrade it to a WebSocket. The line contains the time until the connection was
+// hijacked and the addresses of the connection. The finished log line is not written for hijacked requests, since the
+// handler may run for a long time and the status code is not meaningful. Like the finished line, the hijacked line is
+// subject to WithOnlyLogPaths, the per-request filter, WithFieldNamespace and WithMaxConcurrentLogging.
+func WithLogRequestEvenOnEarlyHijack() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logHijack = true
+	}
+}
+
+// WithFieldNamespace nests the fields of the request log lines in an object with the given name, to avoid collisions
+// with fields like "event" or "url" that are used by the application. This works for every formatter. Fields added to
+// the logger of the request, like the trace fields and the request ID, are shared with the log lines of the
+// application and are not nested. An empty name disables the namespace.
+func WithFieldNamespace(name string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.fieldNamespace = name
+	}
+}
+
+// WithSortedFields sorts the fields of the request log lines by key before they are written, so the output is
+// deterministic. This is useful for log processors that are sensitive to the field order and for golden file tests.
+// Fields added to the logger of the request (the trace and request ID fields for example) are written before the
+// sorted fields, since zap encodes them when they are added.
+func WithSortedFields() HandlerOption {
+	return func(options *handlerOptions) {
+		options.sortFields = true
+	}
+}
+
+// WithErrorFieldFromPanic passes the value the handler panicked with and the stack trace to the formatter, as
+// ResponseInfo.PanicValue and ResponseInfo.PanicStack. The Elastic Common Schema formatter logs them in the "error"
+// object, so panics show up in ECS error dashboards. The value can only be read by recovering the panic, so it is only
+// set if WithRecover is used. Without WithRecover the panic is not touched, only the stack trace is passed on.
+func WithErrorFieldFromPanic() HandlerOption {
+	return func(options *handlerOptions) {
+		options.panicErrorFields = true
+	}
+}
+
+// WithPanicLevel sets the level used to log requests where the handler panicked (default: error).
+func WithPanicLevel(level zapcore.Level) HandlerOption {
+	return func(options *handlerOptions) {
+		options.panicLevel = level
+	}
+}
+
+// WithPanicStackTrace adds the stack trace of a panicking handler to the panic log line as "panic.stack_trace".
+// A hash of the logical panic location is logged as "panic.stack_hash". Goroutine IDs, arguments and addresses are
+// not part of the hash, so identical panics can be grouped in alerting.
+func WithPanicStackTrace() HandlerOption {
+	return func(options *handlerOptions) {
+		options.panicStackTrace = true
+	}
+}
+
+// WithRecover recovers panics of the handler after they are logged (default: false). A 500 status code is written if
+// the handler did not write a response header yet. Panics with http.ErrAbortHandler are never recovered, since the
+// handler explicitly asks the server to abort the response. Only enable this if no other middleware or the server
+// itself needs to see the panic.
+func WithRecover(enabled bool) HandlerOption {
+	return func(options *handlerOptions) {
+		options.recoverPanics = enabled
+	}
+}
+
+// WithSpanStatus logs the status of the active OpenTelemetry span once the handler is done as "span.status" and
+// "span.status_message". This is the opinion of the application about the success of the request, which can differ from
+// the HTTP status. The trace API does not expose the status of a span, fn reads it from the span implementation, see
+// SpanStatusFunc. This keeps the OpenTelemetry SDK out of the dependencies of zaphttp. Nothing is logged for spans that
+// are not recording or when the span status is not set.
+func WithSpanStatus(fn SpanStatusFunc) HandlerOption {
+	return func(options *handlerOptions) {
+		options.spanStatusFn = fn
+	}
+}
+
+// WithLevelFunc sets the function used to pick the level of the line written once a request completes based on the
+// status code (default: DefaultLevelFunc). The chosen level is also passed to the per-request filter.
+func WithLevelFunc(fn LevelFunc) HandlerOption {
+	return func(options *handlerOptions) {
+		options.levelFn = fn
+	}
+}
+
+// WithFixedCompletionLevel logs the line written once a request completes at level, regardless of the status code.
+// The message still depends on the status code. The start and panic lines are not affected. Options that adjust the
+// level based on the response, like WithGRPCStatus and WithDowngradeLevelOnHeader, are applied on top of this level.
+func WithFixedCompletionLevel(level zapcore.Level) HandlerOption {
+	return func(options *handlerOptions) {
+		options.fixedCompletionLevel = &level
+	}
+}
+
+// WithSlowRequestThreshold marks successful requests (status code below 400) that took longer than threshold with a
+// "slow" field and logs them at level. The level is only raised: if the level function or WithFixedCompletionLevel
+// already picked a higher level, that level is kept.
+func WithSlowRequestThreshold(threshold time.Duration, level zapcore.Level) HandlerOption {
+	return func(options *handlerOptions) {
+		options.slowRequestThreshold = threshold
+		options.slowRequestLevel = level
+	}
+}
+
+// WithDeadlineExceededLevel logs requests whose context deadline was exceeded when the handler returned at level,
+// instead of the level based on the status code. These requests are marked with a "deadline_exceeded" field.
+func WithDeadlineExceededLevel(level zapcore.Level) HandlerOption {
+	return func(options *handlerOptions) {
+		options.deadlineExceededLevel = &level
+	}
+}
+
+// WithClientDisconnected marks requests whose context was canceled by the time the handler returned with a
+// "client_disconnected" field. net/http cancels the context when the client closes the connection. The status code of
+// these requests is usually not the one the client saw, the client stopped waiting for the response. Requests that
+// exceeded a server-side deadline are not marked, use WithDeadlineExceededLevel for those.
+func WithClientDisconnected() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logClientDisconnected = true
+	}
+}
+
+// WithClientDisconnectedLevel is like WithClientDisconnected, but also lowers the level of these requests to level, so
+// requests aborted by the client don't show up as server errors. The level is only lowered, never raised.
+func WithClientDisconnectedLevel(level zapcore.Level) HandlerOption {
+	return func(options *handlerOptions) {
+		options.logClientDisconnected = true
+		options.clientDisconnectLevel = &level
+	}
+}
+
+// WithPanicResponseBody sets the body of the 500 response written when a panic is recovered using WithRecover, for
+// example a JSON error. The body is only written if the handler did not write a response yet.
+func WithPanicResponseBody(contentType string, body []byte) HandlerOption {
+	return func(options *handlerOptions) {
+		options.panicResponseContentType = contentType
+		options.panicResponseBody = body
+	}
+}
+
+// WithClock sets the function used by the handler to read the current time (default: time.Now). The start time of a
+// request and all durations measured by the handler, like the latency and time to first byte, use this clock. This is
+// mainly useful to make tests that assert on timing fields deterministic. Timeouts, like the maximum wait of
+// WithMaxConcurrentLogging, and the connection age logged by WithConnectionStats still use the real time.
+func WithClock(now func() time.Time) HandlerOption {
+	return func(options *handlerOptions) {
+		options.clock = now
+	}
+}
+
+// WithMessages overrides the messages used for the log lines written by the handler. Empty fields in messages keep their
+// current value, so only the messages that need to change have to be set.
+func WithMessages(messages Messages) HandlerOption {
+	return func(options *handlerOptions) {
+		options.messages = options.messages.merge(messages)
+	}
+}
+
+// WithRequestHeaderSize logs the total size of the request headers in bytes as "request.headers_bytes". The size is
+// calculated as the headers would be sent over HTTP/1.1, so for every value the name, value, ": " and "\r\n" are
+// counted. If the size is above threshold, "oversized_headers" is set to true. Use a threshold of 0 to only log the
+// size. This is diagnostic only, requests with oversized headers are not rejected.
+func WithRequestHeaderSize(threshold int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.logHeaderSize = true
+		options.oversizedHeaderThreshold = threshold
+	}
+}
+
+// WithStartMessage controls the log line written when a request is received. It is logged at debug level by default,
+// use this option to disable it or to log it at another level without changing the level of the logger. For example,
+// to log the start of requests at info level:
+//
+//	zaphttp.WithStartMessage(true, zapcore.InfoLevel)
+func WithStartMessage(enabled bool, level zapcore.Level) HandlerOption {
+	return func(options *handlerOptions) {
+		options.logStart = enabled
+		options.startLevel = level
+	}
+}
+
+// WithAuthority logs the authority the request was sent to as "http.authority". This is the Host header for HTTP/1.x
+// and the :authority pseudo-header for HTTP/2. If a proxy set the X-Forwarded-Host header, it is logged separately as
+// "http.forwarded_host", so routing issues between multiple proxies are visible.
+func WithAuthority() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logAuthority = true
+	}
+}
+
+// WithServerAddress sets the function used to resolve the address of the server, for example from the configuration
+// or the Host header. It is passed to the formatter as ResponseInfo.ServerAddress. By default, and when fn returns an
+// empty string, formatters use the local address of the connection (http.LocalAddrContextKey), which is not available
+// in all setups.
+func WithServerAddress(fn func(req *http.Request) string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.serverAddressFn = fn
+	}
+}
+
+// WithMinimalPreset configures the handler for minimal logging overhead. The debug message at the start of a request is
+// disabled and every request results in a single log line that only contains the status code and latency of the
+// request, plus the "trace_id" and "span_id" of the active span if there is one (see MinimalTraceFormatter).
+// The level of the line still depends on the status code, so server errors are logged at error level. Combine it with
+// WithFixedCompletionLevel(zapcore.InfoLevel) to log every request at info level.
+func WithMinimalPreset() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logStart = false
+		options.traceFormatter = MinimalTraceFormatter
+		options.requestFormatter = minimalRequestFormatter
+	}
+}
+
+// WithUserFromContext logs the authenticated user stored in the request context under key as fieldName. Use the
+// Elastic Common Schema names "user.id" or "user.name" as field name to make the field searchable in Elastic.
+// The option can be supplied multiple times to log both.
+//
+// Context values only flow from outer to inner handlers, so the authentication middleware that stores the user in
+// the context must wrap the zaphttp handler: authMiddleware(requestLogger(mux)). The field is added to the per-request
+// logger, so it is present on the completion line and on every message logged using FromContext.
+func WithUserFromContext(key any, fieldName string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.contextFields = append(options.contextFields, contextField{key: key, name: fieldName})
+	}
+}
+
+// WithContextFields adds the fields returned by fn to the per-request logger. Fn is called for every request with the
+// request context, after all middleware wrapping the zaphttp handler populated it. This is useful to add values like a
+// user or tenant ID without writing a custom PerRequestLoggerFunc. The option can be supplied multiple times.
+func WithContextFields(fn func(ctx context.Context) []zap.Field) HandlerOption {
+	return func(options *handlerOptions) {
+		options.contextFieldFuncs = append(options.contextFieldFuncs, fn)
+	}
+}
+
+// WithPresenceField logs a boolean field named fieldName that indicates if the request context contains a non-nil
+// value for key, without logging the value itself. This is useful for values like consent tokens that must not end up
+// in the logs. Like WithUserFromContext, the value has to be stored in the context by middleware wrapping the zaphttp
+// handler.
+func WithPresenceField(key any, fieldName string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.contextFields = append(options.contextFields, contextField{key: key, name: fieldName, presenceOnly: true})
+	}
+}
+
+// WithRequestID reads the ID of the request from the header with the given name, for example "X-Request-ID". If the
+// header is absent, empty, longer than 128 bytes or contains characters other than letters, digits and "-._~:/+=", an ID
+// is generated using gen (default: NewRequestID). The ID is logged as "request_id" on every line logged for the
+// request, set in the response header and can be read using RequestIDFromContext.
+func WithRequestID(headerName string, gen func() string) HandlerOption {
+	return func(options *handlerOptions) {
+		if gen == nil {
+			gen = NewRequestID
+		}
+		options.requestIDHeader = headerName
+		options.requestIDGenerator = gen
+	}
+}
+
+// WithEventID adds a random "event_id" field to every line logged for a request, including the start, finished and
+// panic lines. This allows joining the start and finished lines of a request in a log viewer when there is no span.
+// The ID is generated using NewRequestID and is not read from, or written to, any header.
+func WithEventID() HandlerOption {
+	return func(options *handlerOptions) {
+		options.eventID = true
+	}
+}
+
+// WithMetricsRecorder passes every request to recorder once the handler is done, including requests where the handler
+// panicked. This allows recording metrics using the status code and latency measured by the handler, without running a
+// second middleware. See the zaphttpprom module for a Prometheus implementation.
+func WithMetricsRecorder(recorder MetricsRecorder) HandlerOption {
+	return func(options *handlerOptions) {
+		options.metricsRecorder = recorder
+	}
+}
+
+// WithRequestIDFromTrace uses the trace ID of the active span as request ID when the request ID header configured
+// using WithRequestID is absent or empty. This unifies both correlation IDs. An ID is only generated if there is no
+// active span either.
+func WithRequestIDFromTrace() HandlerOption {
+	return func(options *handlerOptions) {
+		options.requestIDFromTrace = true
+	}
+}
+
+// WithStatusCounts counts the number of completed requests per status code. The counts can be read using
+// Handler.StatusCounts.
+func WithStatusCounts() HandlerOption {
+	return func(options *handlerOptions) {
+		options.countStatusCodes = true
+	}
+}
+
+// WithLatencyHistogram keeps in-memory latency histograms of all requests handled, grouped by the route pattern (see
+// WithRoutePatternFunc). The histograms can be read using Handler.LatencyHistogram. Buckets are the upper bounds
+// of the histogram buckets, DefaultLatencyHistogramBuckets is used when no buckets are supplied.
+func WithLatencyHistogram(buckets ...time.Duration) HandlerOption {
+	return func(options *handlerOptions) {
+		if len(buckets) == 0 {
+			buckets = DefaultLatencyHistogramBuckets
+		}
+		options.latencyHistogramBuckets = buckets
+	}
+}
+
+// WithBodyPreview logs the first maxBytes of the request body as "request.body_preview". By default only bodies with
+// a textual content type (text/*, JSON, XML, form data) are previewed, use WithBodyPreviewContentTypes to change this.
+// The body is still fully readable by the handler.
+// When onErrorOnly is true the preview is only logged for requests that failed (status code >= 400) or panicked.
+//
+// The preview is captured while the handler reads the body, nothing is read before the handler is called, so a slow
+// client never delays the handler. The preview only contains the part of the body the handler read, nothing is logged
+// if the handler did not read the body. The preview is marked as truncated if the handler read more than maxBytes.
+func WithBodyPreview(maxBytes int, onErrorOnly bool) HandlerOption {
+	return func(options *handlerOptions) {
+		options.bodyPreviewMaxBytes = maxBytes
+		options.bodyPreviewOnErrorOnly = onErrorOnly
+	}
+}
+
+// WithBodyPreviewContentTypes sets the media types of request bodies that are previewed, for example
+// "application/json". A media type ending in "/*" matches all subtypes, e.g. "text/*".
+func WithBodyPreviewContentTypes(mediaTypes ...string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.bodyPreviewContentTypes = newContentTypeMatcher(mediaTypes)
+	}
+}
+
+// WithResponseBodyLineCount logs the number of newlines written to the response body as "response.line_count", a
+// cheap content metric for text and NDJSON streams. Only responses with one of the media types are counted, a media
+// type ending in "/*" matches all subtypes. Without media types, "text/plain" and "application/x-ndjson" responses are
+// counted. The Content-Type header must be set before the response header is written.
+func WithResponseBodyLineCount(mediaTypes ...string) HandlerOption {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{"text/plain", "application/x-ndjson"}
+	}
+	return func(options *handlerOptions) {
+		options.lineCountContentTypes = newContentTypeMatcher(mediaTypes)
+	}
+}
+
+// WithRequestBodyLogging passes up to maxBytes of the request body to the formatter as ResponseInfo.RequestBody, for
+// debugging. Only bodies with one of the media types are captured, a media type ending in "/*" matches all subtypes.
+// The body is copied while the handler reads it, the handler still receives the full body. Bodies the handler did not
+// read are not logged. The Elastic Common Schema formatter logs the body as "http.request.body.content", binary bodies
+// are base64 encoded. Request bodies often contain personal data and credentials, do not enable this in production.
+func WithRequestBodyLogging(maxBytes int, contentTypes []string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.requestBodyMaxBytes = maxBytes
+		options.requestBodyContentTypes = newContentTypeMatcher(contentTypes)
+	}
+}
+
+// WithErrorResponseBody logs up to maxBytes of the response body as "response.body" for responses with a status
+// code of at least minStatus, for example http.StatusInternalServerError. Error responses often contain the details
+// needed to debug them. The body is copied while it is written, the response sent to the client is not changed. Only
+// the first maxBytes are kept in memory, so streaming responses are not buffered.
+func WithErrorResponseBody(maxBytes, minStatus int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.errorBodyMaxBytes = maxBytes
+		options.errorBodyMinStatus = minStatus
+	}
+}
+
+// WithLogWhenNoRoute adds a "no_route_matched" field to 404 responses for requests that did not match any route
+// pattern of http.ServeMux. This helps identifying clients calling endpoints that do not exist.
+// Detection relies on http.ServeMux setting the pattern on the request that is passed to it, so the mux must be
+// wrapped directly by the zaphttp handler or by middleware that passes the same request along. The pattern is only set
+// since Go 1.23, when built with Go 1.22 every 404 response is flagged.
+func WithLogWhenNoRoute() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logNoRoute = true
+	}
+}
+
+// WithGRPCStatus logs the gRPC status code and message sent by gRPC handlers in the grpc-status and grpc-message
+// trailers as "grpc.status_code" and "grpc.message". Since gRPC responses always use HTTP status 200, the log level is
+// raised based on the gRPC status: warn for errors caused by the client, error for all other failures.
+func WithGRPCStatus() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logGRPCStatus = true
+	}
+}
+
+// WithGRPCGatewayFields logs the gRPC status and metadata of requests handled by grpc-gateway. The grpc-status and
+// grpc-message response trailers are logged as "grpc.status_code" and "grpc.message", next to the HTTP status set by
+// the gateway. gRPC header metadata forwarded as "Grpc-Metadata-<key>" response headers is logged as
+// "grpc.metadata.<key>", trailer metadata forwarded as "Grpc-Trailer-<key>" trailers as "grpc.trailer.<key>". Metadata
+// keys that commonly contain credentials are not logged. The trailers are also available to formatters as
+// ResponseInfo.Trailers. Unlike WithGRPCStatus, the log level is not changed, do not combine both options.
+func WithGRPCGatewayFields() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logGRPCGateway = true
+	}
+}
+
+// WithConnectionIDKey logs the connection ID stored in the request context under key as "connection.id". This allows
+// grouping requests that are multiplexed over the same HTTP/2 connection. The connection ID is typically injected
+// using the ConnContext hook of http.Server:
+//
+//	s := &http.Server{
+//		ConnContext: func(ctx context.Context, _ net.Conn) context.Context {
+//			return context.WithValue(ctx, connIDKey{}, nextConnID())
+//		},
+//	}
+func WithConnectionIDKey(key any) HandlerOption {
+	return func(options *handlerOptions) {
+		options.contextFields = append(options.contextFields, contextField{key: key, name: "connection.id"})
+	}
+}
+
+// WithConnectionStats logs statistics about the client connection: the time since the connection was accepted as
+// "connection.age" and the number of requests served on the connection, including the current one, as
+// "connection.requests". This requires InstallConnectionStats to be called on the http.Server, nothing is logged
+// otherwise.
+func WithConnectionStats() HandlerOption {
+	return func(options *handlerOptions) {
+		options.connectionStats = true
+	}
+}
+
+// WithPathNormalization logs a normalized version of the request path as "url.normalized_path". Repeated slashes are
+// collapsed and "." and ".." segments are resolved, the original path is still logged by the formatter.
+// If normalization changed the path, a "path_anomaly" field is added since this may indicate path traversal probing.
+func WithPathNormalization() HandlerOption {
+	return func(options *handlerOptions) {
+		options.normalizePath = true
+	}
+}
+
+// WithProtocolDowngradeDetection adds a "protocol_downgrade" field to requests where the client negotiated HTTP/2
+// using TLS ALPN, but the request was served using HTTP/1.x. This usually indicates a misconfigured proxy or
+// load balancer.
+func WithProtocolDowngradeDetection() HandlerOption {
+	return func(options *handlerOptions) {
+		options.detectProtocolDowngrade = true
+	}
+}
+
+// WithLogSkipReasons logs a debug message whenever a request log line is suppressed, for example by the per-request
+// filter. The message contains the reason and the level and message of the suppressed line. This is meant for
+// troubleshooting why requests are missing from the logs.
+func WithLogSkipReasons() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logSkipReasons = true
+	}
+}
+
+// WithMaxConcurrentLogging limits the number of request goroutines that write log entries at the same time to n.
+// This protects request latency against slow log sinks. If the limit is reached, a request waits up to maxWait for
+// another request to finish writing. If that takes too long the log entry is dropped, the number of dropped entries
+// can be read using Handler.DroppedLogs.
+func WithMaxConcurrentLogging(n int, maxWait time.Duration) HandlerOption {
+	return func(options *handlerOptions) {
+		options.maxConcurrentLogging = n
+		options.maxConcurrentLoggingWait = maxWait
+	}
+}
+
+// WithBaggageLabels adds all OpenTelemetry baggage members of the request to the per-request logger as an Elastic
+// Common Schema "labels" object. Baggage keys are sanitized to valid ECS label keys by replacing all characters other
+// than letters, digits and underscores with an underscore.
+func WithBaggageLabels() HandlerOption {
+	return func(options *handlerOptions) {
+		options.baggageLabels = true
+	}
+}
+
+// WithOnFirstWrite registers a callback that is called once per request, when the handler writes the response header
+// (either explicitly or by the first call to Write). This can be used to track the time to first byte of streaming
+// endpoints. The callback runs synchronously on the request goroutine, so it should be fast.
+func WithOnFirstWrite(fn FirstWriteFunc) HandlerOption {
+	return func(options *handlerOptions) {
+		options.onFirstWrite = fn
+	}
+}
+
+// WithReplayIDHeader marks requests replayed by debugging tools. When the request contains the header (for example
+// "X-Replay-ID"), its value is logged as "replay_id" together with an "is_replay" field.
+func WithReplayIDHeader(headerName string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.replayIDHeader = headerName
+	}
+}
+
+// WithFieldRedactor runs fn over all fields of the request log lines before they are written, allowing values like
+// credit card numbers or email addresses to be masked. Fields that are already part of the per-request logger (like
+// trace fields) are not passed to fn.
+// Every field is encoded to an intermediate map before fn is called, this is expensive and adds a significant amount
+// of allocations to every request that is logged.
+func WithFieldRedactor(fn FieldRedactorFunc) HandlerOption {
+	return func(options *handlerOptions) {
+		options.fieldRedactor = fn
+	}
+}
+
+// WithTimeoutBudget logs the time budget of requests that have a context deadline. The total budget (deadline minus
+// request start) is logged as "budget" and the fraction of it used by the handler as "budget_used_fraction".
+// A fraction close to or above 1 means the request (almost) timed out. Requests without deadline are not affected.
+func WithTimeoutBudget() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logTimeoutBudget = true
+	}
+}
+
+// WithCORSPreflightDetection marks CORS preflight requests (OPTIONS requests with an Access-Control-Request-Method
+// header) with a "cors_preflight" field. The requested method and headers are logged as "cors.request_method" and
+// "cors.request_headers".
+func WithCORSPreflightDetection() HandlerOption {
+	return func(options *handlerOptions) {
+		options.detectCORSPreflight = true
+	}
+}
+
+// WithRequestHeaders captures the values of the listed request headers, so formatters can log them. Only the listed
+// headers are captured to prevent leaking secrets like the Authorization header. The ECS formatter logs them as
+// http.request.headers, keyed by the lowercased header name.
+func WithRequestHeaders(names []string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.requestHeaders = append(options.requestHeaders, names...)
+	}
+}
+
+// WithRequestHeaderFields logs request headers as fields on every line logged for the request. The mapping maps the
+// header name to the name of the log field, for example {"X-Tenant-ID": "tenant_id"}. Multiple values of the same
+// header are joined with commas, absent headers are not logged. Headers that commonly contain credentials, like
+// Authorization, Cookie and X-Api-Key, are never logged and are ignored if present in the mapping.
+func WithRequestHeaderFields(mapping map[string]string) HandlerOption {
+	return func(options *handlerOptions) {
+		if options.requestHeaderFields == nil {
+			options.requestHeaderFields = make(map[string]string, len(mapping))
+		}
+		for header, field := range mapping {
+			if !isSensitiveHeader(header) {
+				options.requestHeaderFields[header] = field
+			}
+		}
+	}
+}
+
+// WithResponseHeaders captures the values of the listed response headers, so formatters can log them. The headers are
+// captured when the response header is written, so the log reflects what was sent to the client. The ECS formatter
+// logs them as http.response.headers, keyed by the lowercased header name.
+func WithResponseHeaders(names []string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.responseHeaders = append(options.responseHeaders, names...)
+	}
+}
+
+// WithResponseHeaderFields logs response headers as top-level fields. The mapping maps the header name to the name of
+// the log field, for example {"X-Cache": "cache_status"}. Like WithResponseHeaders, the headers are captured when the
+// response header is written. Multiple values of the same header are joined with commas, absent headers are not logged.
+func WithResponseHeaderFields(mapping map[string]string) HandlerOption {
+	return func(options *handlerOptions) {
+		if options.responseHeaderFields == nil {
+			options.responseHeaderFields = make(map[string]string, len(mapping))
+		}
+		maps.Copy(options.responseHeaderFields, mapping)
+	}
+}
+
+// WithRedactedQueryParams replaces the values of the listed query parameters with "REDACTED" in the URL passed to
+// the request formatter, for example for ?token=abc or ?api_key=xyz. The handler still sees the original values.
+// Parameter names are case-sensitive, parameters with an empty value are logged as is.
+func WithRedactedQueryParams(params []string) HandlerOption {
+	return func(options *handlerOptions) {
+		if options.redactedQueryParams == nil {
+			options.redactedQueryParams = make(map[string]struct{}, len(params))
+		}
+		for _, param := range params {
+			options.redactedQueryParams[param] = struct{}{}
+		}
+	}
+}
+
+// WithUpstreamLatencyHeader logs the latency reported by an upstream service or proxy in the header with the given name
+// as "upstream_latency_ms". The header is read from the response, or from the request if the response does not contain
+// it. Plain numbers are interpreted as seconds (like nginx's $upstream_response_time), values with a unit like "15ms"
+// are parsed as a duration. Absent and invalid values are ignored.
+func WithUpstreamLatencyHeader(name string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.upstreamLatencyHeader = name
+	}
+}
+
+// WithUpgradeDetection marks requests that ask for a protocol upgrade (a "Connection: Upgrade" header combined with an
+// Upgrade header, for example for WebSockets or h2c) with an "upgrade_requested" field. The requested protocol is
+// logged as "request.upgrade".
+func WithUpgradeDetection() HandlerOption {
+	return func(options *handlerOptions) {
+		options.detectUpgrade = true
+	}
+}
+
+// WithStatusOverrideDetection detects handlers or middleware that call WriteHeader again with a different status code
+// after the response header was sent, for example an error page middleware that sees the writer through Unwrap.
+// net/http ignores the later call, the client receives the original status. The original status is the status that is
+// logged and determines the level, the mismatch is logged as "status_overridden" together with "status.original_code"
+// and "status.override_code".
+//
+// Only calls that pass through the writer of the handler are detected. Middleware that wraps the logging handler writes
+// to a writer the handler never sees, after the request was already logged. Detecting this is not supported, wrap such
+// middleware in the logging handler instead.
+func WithStatusOverrideDetection() HandlerOption {
+	return func(options *handlerOptions) {
+		options.detectStatusOverride = true
+	}
+}
+
+// WithImplicitStatusField marks requests where the handler returned without calling Write or WriteHeader with an
+// "implicit_status" field. net/http sends a 200 OK for these requests, which is the status that is logged, but it often
+// means the handler forgot to write a response.
+func WithImplicitStatusField() HandlerOption {
+	return func(options *handlerOptions) {
+		options.logImplicitStatus = true
+	}
+}
+
+// WithResponseWriterWrapper allows wrapping the http.ResponseWriter passed to the handler with custom
+// instrumentation. The wrapper receives the writer that zaphttp uses to record the response status and is passed to
+// the handler in its place, so the handler writes to the wrapper, which has to forward calls to the zaphttp writer.
+// Writes that are not forwarded are not seen by zaphttp.
+func WithResponseWriterWrapper(fn func(http.ResponseWriter) http.ResponseWriter) HandlerOption {
+	return func(options *handlerOptions) {
+		options.responseWriterWrapper = fn
+	}
+}
+
+// WithDowngradeLevelOnHeader lowers the level of the log line written when a request completes to level, if the
+// request has a header name with the given value. This is useful to reduce the log volume of internal traffic, for
+// example requests marked by a service mesh. The level is never raised, and the option can be supplied multiple times.
+//
+// Only lines below warn level are downgraded. Any client can send the header, so client and server errors are always
+// logged at their own level. Strip the header from external requests at the edge if the downgrade must not apply to
+// them.
+func WithDowngradeLevelOnHeader(name, value string, level zapcore.Level) HandlerOption {
+	return func(options *handlerOptions) {
+		options.headerLevelDowngrades = append(options.headerLevelDowngrades, headerLevelDowngrade{
+			header: name,
+			value:  value,
+			level:  level,
+		})
+	}
+}
+
+// WithStatusClassSampling samples the log lines written when requests complete based on the class of the status code.
+// Rates maps a status class (2 for 2xx, 4 for 4xx, etc.) to the probability that a request is logged, between 0 and 1.
+// Classes that are not in the map are always logged. For example, to log all errors, 10% of the redirects and 1% of
+// the successful requests:
+//
+//	zaphttp.WithStatusClassSampling(map[int]float64{2: 0.01, 3: 0.1})
+//
+// Sampling happens before the per-request filter: a request has to be sampled and pass the filter to be logged.
+func WithStatusClassSampling(rates map[int]float64) HandlerOption {
+	return func(options *handlerOptions) {
+		options.statusClassSampling = rates
+	}
+}
+
+// WithSampling samples the info level log lines written when requests complete, like zap's sampler. Every second, the
+// first initial requests for a route are logged, and every thereafter-th request after that. Requests are grouped by the
+// route pattern (see WithRoutePatternFunc), so a burst of requests to one endpoint does not suppress the logs of
+// another. Requests that did not match a route share one group, so unbounded request paths do not grow the sampler
+// state. Warnings, errors and the other log lines are never sampled.
+//
+// Sampling happens before the per-request filter: a request has to be sampled and pass the filter to be logged.
+// Requests dropped by the filter still count towards the sampling counts of their route.
+func WithSampling(initial, thereafter int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.samplingInitial = initial
+		options.samplingThereafter = thereafter
+	}
+}
+
+// WithDynamicSampling logs 1 in rate identical successful requests. Requests are identical if keyFn returns the same key
+// and they completed with the same status code, the first request of every key is always logged. If keyFn is nil,
+// requests are grouped by method and route (see WithRoutePatternFunc). Requests with a status code of 400 or higher,
+// and requests logged at a level above info, are never sampled.
+//
+// Counts are kept for the maxKeys most recently seen keys (1000 if maxKeys <= 0). When a key is evicted, its next
+// request is logged as if it was the first.
+//
+// Dynamic sampling runs when the request completes, after WithStatusClassSampling and WithSampling and before the
+// per-request filter: a request has to pass all of them to be logged. Requests dropped by the filter still count towards
+// the sampling counts of their key.
+func WithDynamicSampling(keyFn DynamicSamplingKeyFunc, rate int, maxKeys int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.dynamicSamplingKeyFn = keyFn
+		options.dynamicSamplingRate = rate
+		options.dynamicSamplingKeys = maxKeys
+	}
+}
+
+// WithSuccessErrorClassifier allows logging successful (2xx) responses as errors. If fn returns true, the request is
+// logged at error level with a "logical_error" field. Since the response body is not available, handlers can mark a
+// request as failed using MarkLogicalError:
+//
+//	zaphttp.WithSuccessErrorClassifier(func(req *http.Request, _ *zaphttp.ResponseInfo) bool {
+//		return zaphttp.IsLogicalError(req.Context())
+//	})
+func WithSuccessErrorClassifier(fn func(req *http.Request, res *ResponseInfo) bool) HandlerOption {
+	return func(options *handlerOptions) {
+		options.successErrorClassifier = fn
+	}
+}
+
+// WithRemoteAddrParser sets the function used to split the client address (req.RemoteAddr) in a host and port
+// (default: DefaultRemoteAddrParser). The host is logged as client address by the built-in formatters.
+func WithRemoteAddrParser(fn RemoteAddrParserFunc) HandlerOption {
+	return func(options *handlerOptions) {
+		options.remoteAddrParser = fn
+	}
+}
+
+// WithRuntimeStatsOnError adds Go runtime stats (heap usage, GC count and number of goroutines) to the log line of
+// requests that failed with a server error or panicked. This helps correlating error spikes with memory pressure.
+// Reading memory stats stops the world, so stats are only read when the log line is written and are shared between
+// requests for up to a second.
+func WithRuntimeStatsOnError() HandlerOption {
+	return func(options *handlerOptions) {
+		options.runtimeStatsOnError = true
+	}
+}
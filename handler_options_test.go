@@ -0,0 +1,1793 @@
+package zaphttp_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithMinimalPreset(t *testing.T) {
+	t.Parallel()
+
+	// Use debug level, the start message should not be logged even though debug logging is enabled.
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithMinimalPreset(),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+	assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+	assert.Equal(t, "HTTP request finished", lines[0].Message)
+
+	fields := lines[0].ContextMap()
+	assert.Len(t, fields, 2)
+	assert.Equal(t, int64(http.StatusOK), fields["status"])
+	assert.Contains(t, fields, "latency")
+}
+
+func TestWithMinimalPresetWithSpan(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithMinimalPreset(),
+	)
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	require.Len(t, lines, 1)
+	// The level still depends on the status code.
+	assert.Equal(t, zapcore.ErrorLevel, lines[0].Level)
+
+	fields := lines[0].ContextMap()
+	assert.Len(t, fields, 4)
+	assert.Equal(t, int64(http.StatusInternalServerError), fields["status"])
+	assert.Equal(t, "0c22384e5a0000000000000000000000", fields["trace_id"])
+	assert.Equal(t, "2b15000000000000", fields["span_id"])
+}
+
+func TestWithUserFromContext(t *testing.T) {
+	t.Parallel()
+
+	type userKey struct{}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithUserFromContext(userKey{}, "user.name"),
+		zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+	)
+
+	// Simulate an authentication middleware that runs before the request logger.
+	authMiddleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := context.WithValue(req.Context(), userKey{}, "alice")
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	authMiddleware(requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "HTTP request finished", lines[0].Message)
+	assert.Equal(t, "alice", lines[0].ContextMap()["user.name"])
+}
+
+func TestWithPanicLevel(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithPanicLevel(zapcore.WarnLevel),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	assert.Panics(t, func() {
+		requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			panic("broken")
+		})).ServeHTTP(rec, req)
+	})
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+	assert.Equal(t, zapcore.WarnLevel, lines[0].Level)
+	assert.Equal(t, "HTTP request panicked", lines[0].Message)
+}
+
+func TestWithRequestHeaderSize(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithRequestHeaderSize(8*1024),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// "X-Small: value\r\n" is 16 bytes.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header = http.Header{"X-Small": []string{"value"}}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 20 headers of "X-Large-NN: <1000 bytes>\r\n" is 20 * 1014 bytes.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header = http.Header{}
+	for i := range 20 {
+		req.Header.Set(fmt.Sprintf("X-Large-%02d", i), strings.Repeat("a", 1000))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+
+	assert.Equal(t, int64(16), lines[0].ContextMap()["request.headers_bytes"])
+	assert.NotContains(t, lines[0].ContextMap(), "oversized_headers")
+
+	assert.Equal(t, int64(20*1014), lines[1].ContextMap()["request.headers_bytes"])
+	assert.Equal(t, true, lines[1].ContextMap()["oversized_headers"])
+}
+
+func TestWithLevelFunc(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	var filteredLevels []zapcore.Level
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithLevelFunc(func(statusCode int) zapcore.Level {
+			if statusCode == http.StatusNotFound {
+				return zapcore.InfoLevel
+			}
+			return zaphttp.DefaultLevelFunc(statusCode)
+		}),
+		zaphttp.WithPerRequestFilter(func(_ *http.Request, level zapcore.Level) bool {
+			filteredLevels = append(filteredLevels, level)
+			return true
+		}),
+	)
+
+	for _, statusCode := range []int{http.StatusNotFound, http.StatusBadRequest} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(statusCode)
+		})).ServeHTTP(rec, req)
+	}
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+	assert.Equal(t, "HTTP request failed due to a client error", lines[0].Message)
+	assert.Equal(t, zapcore.WarnLevel, lines[1].Level)
+
+	// The filter also receives the debug level of the start lines.
+	assert.Equal(t, []zapcore.Level{
+		zapcore.DebugLevel, zapcore.InfoLevel,
+		zapcore.DebugLevel, zapcore.WarnLevel,
+	}, filteredLevels)
+}
+
+func TestWithFixedCompletionLevel(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithFixedCompletionLevel(zapcore.InfoLevel),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, zapcore.DebugLevel, lines[0].Level)
+	assert.Equal(t, zapcore.InfoLevel, lines[1].Level)
+	assert.Equal(t, "HTTP request failed", lines[1].Message)
+}
+
+func TestWithMessages(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithMessages(zaphttp.Messages{Panicked: "handler crashed"}),
+		zaphttp.WithRecover(true),
+	)
+
+	tests := []struct {
+		statusCode int
+		panics     bool
+		expected   string
+	}{
+		{statusCode: http.StatusOK, expected: "HTTP request finished"},
+		{statusCode: http.StatusNotFound, expected: "HTTP request failed due to a client error"},
+		{statusCode: http.StatusBadGateway, expected: "HTTP request failed"},
+		{panics: true, expected: "handler crashed"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if tt.panics {
+				panic("broken")
+			}
+			w.WriteHeader(tt.statusCode)
+		})).ServeHTTP(rec, req)
+	}
+
+	lines := logs.All()
+	assert.Len(t, lines, 2*len(tests))
+	for i, tt := range tests {
+		assert.Equal(t, "Received HTTP request", lines[2*i].Message)
+		assert.Equal(t, tt.expected, lines[2*i+1].Message)
+	}
+}
+
+func TestWithRecover(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should recover and write a 500 status code", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRecover(true),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		assert.NotPanics(t, func() {
+			requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				panic("broken")
+			})).ServeHTTP(rec, req)
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "HTTP request panicked", lines[0].Message)
+
+		// The logged status is the status sent to the client.
+		httpMap, ok := lines[0].ContextMap()["http"].(map[string]interface{})
+		require.True(t, ok, "http field should be a map")
+		responseMap, ok := httpMap["response"].(map[string]interface{})
+		require.True(t, ok, "response field should be a map")
+		assert.Equal(t, http.StatusInternalServerError, responseMap["status_code"])
+	})
+
+	t.Run("Should keep the status code if the header was already written", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithRecover(true),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		assert.NotPanics(t, func() {
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("broken")
+			})).ServeHTTP(rec, req)
+		})
+
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	})
+
+	t.Run("Should write the panic response body", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithRecover(true),
+			zaphttp.WithPanicResponseBody("application/json", []byte(`{"error":"internal"}`)),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			panic("broken")
+		})).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"error":"internal"}`, rec.Body.String())
+	})
+
+	t.Run("Should not write the panic response body if the handler already responded", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithRecover(true),
+			zaphttp.WithPanicResponseBody("application/json", []byte(`{"error":"internal"}`)),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("partial"))
+			panic("broken")
+		})).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "partial", rec.Body.String())
+	})
+
+	t.Run("Should not recover http.ErrAbortHandler", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithRecover(true),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				panic(http.ErrAbortHandler)
+			})).ServeHTTP(rec, req)
+		})
+	})
+
+	t.Run("Should re-panic when disabled", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithRecover(false),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		assert.Panics(t, func() {
+			requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				panic("broken")
+			})).ServeHTTP(rec, req)
+		})
+	})
+}
+
+func TestWithLogWhenNoRoute(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithLogWhenNoRoute(),
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/exists", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := requestLogger(mux)
+
+	for _, path := range []string{"/does-not-exist", "/exists"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+	}
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+
+	assert.Equal(t, zapcore.WarnLevel, lines[0].Level)
+	assert.Equal(t, true, lines[0].ContextMap()["no_route_matched"])
+
+	// A handler returning a 404 for a matched route should not be flagged.
+	assert.Equal(t, zapcore.WarnLevel, lines[1].Level)
+	assert.NotContains(t, lines[1].ContextMap(), "no_route_matched")
+}
+
+func TestWithConnectionIDKey(t *testing.T) {
+	t.Parallel()
+
+	type connIDKey struct{}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithConnectionIDKey(connIDKey{}),
+		zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+	)
+
+	// Simulate the context created by http.Server.ConnContext.
+	ctx := context.WithValue(context.Background(), connIDKey{}, "conn-42")
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "conn-42", lines[0].ContextMap()["connection.id"])
+}
+
+func TestWithPathNormalization(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path       string
+		normalized string
+		anomaly    bool
+	}{
+		{"/api/users/1", "/api/users/1", false},
+		{"/api/users/", "/api/users/", false},
+		{"/api//users///1", "/api/users/1", true},
+		{"/api/./users/../users/1", "/api/users/1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithPathNormalization(),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			lines := logs.All()
+			assert.Len(t, lines, 1)
+			assert.Equal(t, tt.normalized, lines[0].ContextMap()["url.normalized_path"])
+			if tt.anomaly {
+				assert.Equal(t, true, lines[0].ContextMap()["path_anomaly"])
+			} else {
+				assert.NotContains(t, lines[0].ContextMap(), "path_anomaly")
+			}
+		})
+	}
+
+	t.Run("OPTIONS *", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithPathNormalization(),
+		)
+
+		req := httptest.NewRequest(http.MethodOptions, "*", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "*", lines[0].ContextMap()["url.normalized_path"])
+		assert.NotContains(t, lines[0].ContextMap(), "path_anomaly")
+	})
+}
+
+func TestWithProtocolDowngradeDetection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		alpn       string
+		protoMajor int
+		downgrade  bool
+	}{
+		{"HTTP/2 negotiated and used", "h2", 2, false},
+		{"HTTP/2 negotiated but HTTP/1.1 used", "h2", 1, true},
+		{"HTTP/1.1 negotiated and used", "http/1.1", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithProtocolDowngradeDetection(),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+			req.TLS.NegotiatedProtocol = tt.alpn
+			req.ProtoMajor = tt.protoMajor
+			rec := httptest.NewRecorder()
+
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			lines := logs.All()
+			assert.Len(t, lines, 1)
+			if tt.downgrade {
+				assert.Equal(t, true, lines[0].ContextMap()["protocol_downgrade"])
+				assert.Equal(t, "h2", lines[0].ContextMap()["tls.next_protocol"])
+			} else {
+				assert.NotContains(t, lines[0].ContextMap(), "protocol_downgrade")
+			}
+		})
+	}
+}
+
+func TestWithLogSkipReasons(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithLogSkipReasons(),
+		zaphttp.WithPerRequestFilter(func(_ *http.Request, level zapcore.Level) bool {
+			// Only suppress the finish message.
+			return level != zapcore.InfoLevel
+		}),
+		zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "Received HTTP request", lines[0].Message)
+
+	assert.Equal(t, zapcore.DebugLevel, lines[1].Level)
+	assert.Equal(t, "HTTP request logging skipped", lines[1].Message)
+	assert.Equal(t, "per_request_filter", lines[1].ContextMap()["skip.reason"])
+	assert.Equal(t, "info", lines[1].ContextMap()["skip.level"])
+	assert.Equal(t, "HTTP request finished", lines[1].ContextMap()["skip.message"])
+}
+
+func TestWithOnFirstWrite(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	var elapsed time.Duration
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(zap.NewNop()),
+		zaphttp.WithOnFirstWrite(func(_ *http.Request, d time.Duration) {
+			calls++
+			elapsed = d
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("first"))
+		_, _ = w.Write([]byte("second"))
+	})).ServeHTTP(rec, req)
+
+	assert.Equal(t, 1, calls)
+	assert.GreaterOrEqual(t, elapsed, 20*time.Millisecond)
+	assert.Less(t, elapsed, 5*time.Second)
+}
+
+func TestWithReplayIDHeader(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithReplayIDHeader("X-Replay-ID"),
+		zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	replayed := httptest.NewRequest(http.MethodGet, "/", nil)
+	replayed.Header.Set("X-Replay-ID", "replay-1")
+	handler.ServeHTTP(httptest.NewRecorder(), replayed)
+
+	live := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), live)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "replay-1", lines[0].ContextMap()["replay_id"])
+	assert.Equal(t, true, lines[0].ContextMap()["is_replay"])
+	assert.Empty(t, lines[1].ContextMap())
+}
+
+func TestWithTimeoutBudget(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should log the used fraction of the budget", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithTimeoutBudget(),
+			zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			time.Sleep(250 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.InDelta(t, 0.25, lines[0].ContextMap()["budget_used_fraction"], 0.1)
+		assert.InDelta(t, time.Second, lines[0].ContextMap()["budget"], float64(100*time.Millisecond))
+	})
+
+	t.Run("Should not log a budget without deadline", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithTimeoutBudget(),
+			zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.NotContains(t, lines[0].ContextMap(), "budget")
+		assert.NotContains(t, lines[0].ContextMap(), "budget_used_fraction")
+	})
+}
+
+func TestWithCORSPreflightDetection(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithCORSPreflightDetection(),
+		zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	preflight.Header.Set("Origin", "https://example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	preflight.Header.Set("Access-Control-Request-Headers", "content-type,x-request-id")
+	handler.ServeHTTP(httptest.NewRecorder(), preflight)
+
+	// A plain OPTIONS request is not a preflight request.
+	options := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), options)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, map[string]interface{}{
+		"cors_preflight":       true,
+		"cors.request_method":  http.MethodPut,
+		"cors.request_headers": "content-type,x-request-id",
+	}, lines[0].ContextMap())
+	assert.Empty(t, lines[1].ContextMap())
+}
+
+// countingWriter counts the number of calls to Write.
+type countingWriter struct {
+	http.ResponseWriter
+	writes int
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	w.writes++
+	return w.ResponseWriter.Write(data)
+}
+
+func TestWithResponseWriterWrapper(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	var wrapper *countingWriter
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithResponseWriterWrapper(func(w http.ResponseWriter) http.ResponseWriter {
+			wrapper = &countingWriter{ResponseWriter: w}
+			return wrapper
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("hello "))
+		_, _ = w.Write([]byte("world"))
+	})).ServeHTTP(rec, req)
+
+	assert.Equal(t, 2, wrapper.writes)
+	assert.Equal(t, "hello world", rec.Body.String())
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+
+	httpMap, ok := lines[0].ContextMap()["http"].(map[string]interface{})
+	assert.True(t, ok, "http field should be a map")
+
+	responseMap, ok := httpMap["response"].(map[string]interface{})
+	assert.True(t, ok, "response field should be a map")
+	assert.Equal(t, 202, responseMap["status_code"])
+}
+
+func TestWithDowngradeLevelOnHeader(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithMinimalPreset(),
+		zaphttp.WithDowngradeLevelOnHeader("X-Internal", "true", zapcore.DebugLevel),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	internal := httptest.NewRequest(http.MethodGet, "/", nil)
+	internal.Header.Set("X-Internal", "true")
+	handler.ServeHTTP(httptest.NewRecorder(), internal)
+
+	external := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), external)
+
+	// Errors can not be hidden by sending the header.
+	internalError := httptest.NewRequest(http.MethodGet, "/error", nil)
+	internalError.Header.Set("X-Internal", "true")
+	handler.ServeHTTP(httptest.NewRecorder(), internalError)
+
+	lines := logs.All()
+	assert.Len(t, lines, 3)
+	assert.Equal(t, zapcore.DebugLevel, lines[0].Level)
+	assert.Equal(t, "HTTP request finished", lines[0].Message)
+	assert.Equal(t, zapcore.InfoLevel, lines[1].Level)
+	assert.Equal(t, zapcore.ErrorLevel, lines[2].Level)
+}
+
+func TestWithSuccessErrorClassifier(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithRequestFormatter(zaphttp.NoopFormatter),
+		zaphttp.WithSuccessErrorClassifier(func(req *http.Request, _ *zaphttp.ResponseInfo) bool {
+			return zaphttp.IsLogicalError(req.Context())
+		}),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has("fail") {
+			zaphttp.MarkLogicalError(req.Context())
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?fail", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, zapcore.ErrorLevel, lines[0].Level)
+	assert.Equal(t, true, lines[0].ContextMap()["logical_error"])
+	assert.Equal(t, zapcore.InfoLevel, lines[1].Level)
+	assert.NotContains(t, lines[1].ContextMap(), "logical_error")
+}
+
+func TestWithUpgradeDetection(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithUpgradeDetection(),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Upgrade header without "Connection: Upgrade" is not an upgrade request.
+	req = httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, true, lines[0].ContextMap()["upgrade_requested"])
+	assert.Equal(t, "websocket", lines[0].ContextMap()["request.upgrade"])
+	assert.NotContains(t, lines[1].ContextMap(), "upgrade_requested")
+	assert.NotContains(t, lines[1].ContextMap(), "request.upgrade")
+}
+
+func TestWithUpstreamLatencyHeader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		requestValue  string
+		responseValue string
+		expected      interface{}
+	}{
+		{name: "Seconds in response", responseValue: "0.125", expected: 125.0},
+		{name: "Duration in request", requestValue: "15ms", expected: 15.0},
+		{name: "Response takes precedence", requestValue: "1", responseValue: "0.002", expected: 2.0},
+		{name: "Invalid value", responseValue: "fast"},
+		{name: "Negative value", responseValue: "-1"},
+		{name: "Absent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithUpstreamLatencyHeader("X-Upstream-Response-Time"),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.requestValue != "" {
+				req.Header.Set("X-Upstream-Response-Time", tt.requestValue)
+			}
+			rec := httptest.NewRecorder()
+
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tt.responseValue != "" {
+					w.Header().Set("X-Upstream-Response-Time", tt.responseValue)
+				}
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			lines := logs.All()
+			assert.Len(t, lines, 1)
+			if tt.expected == nil {
+				assert.NotContains(t, lines[0].ContextMap(), "upstream_latency_ms")
+			} else {
+				assert.Equal(t, tt.expected, lines[0].ContextMap()["upstream_latency_ms"])
+			}
+		})
+	}
+}
+
+// unwrappingWriter is a response writer wrapper that exposes the writer it wraps, like most middleware.
+type unwrappingWriter struct {
+	http.ResponseWriter
+}
+
+func (w *unwrappingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestWithStatusOverrideDetection(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithStatusOverrideDetection(),
+		zaphttp.WithResponseWriterWrapper(func(w http.ResponseWriter) http.ResponseWriter {
+			return &unwrappingWriter{ResponseWriter: w}
+		}),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if req.URL.Path == "/override" {
+			// Simulate an error page middleware writing a status through the unwrap chain.
+			unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+			if ok {
+				unwrapper.Unwrap().WriteHeader(http.StatusBadGateway)
+			}
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/override", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	// The client received the original status, which determines the level and the logged status code.
+	assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+	assert.Equal(t, "HTTP request finished", lines[0].Message)
+	httpMap, ok := lines[0].ContextMap()["http"].(map[string]interface{})
+	require.True(t, ok, "http field should be a map")
+	responseMap, ok := httpMap["response"].(map[string]interface{})
+	require.True(t, ok, "response field should be a map")
+	assert.Equal(t, http.StatusOK, responseMap["status_code"])
+	assert.Equal(t, true, lines[0].ContextMap()["status_overridden"])
+	assert.Equal(t, int64(http.StatusOK), lines[0].ContextMap()["status.original_code"])
+	assert.Equal(t, int64(http.StatusBadGateway), lines[0].ContextMap()["status.override_code"])
+	assert.NotContains(t, lines[1].ContextMap(), "status_overridden")
+}
+
+func TestWithPresenceField(t *testing.T) {
+	t.Parallel()
+
+	type consentKey struct{}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithPresenceField(consentKey{}, "consent_present"),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), consentKey{}, "secret-consent-token"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, true, lines[0].ContextMap()["consent_present"])
+	assert.Equal(t, false, lines[1].ContextMap()["consent_present"])
+}
+
+func TestWithDeadlineExceededLevel(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithDeadlineExceededLevel(zapcore.WarnLevel),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Canceled requests are not affected.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	req = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, zapcore.WarnLevel, lines[0].Level)
+	assert.Equal(t, true, lines[0].ContextMap()["deadline_exceeded"])
+	assert.Equal(t, zapcore.ErrorLevel, lines[1].Level)
+	assert.NotContains(t, lines[1].ContextMap(), "deadline_exceeded")
+}
+
+func TestWithClientDisconnected(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should mark canceled requests", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithClientDisconnected(),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		lines := logs.All()
+		assert.Len(t, lines, 2)
+		assert.Equal(t, zapcore.ErrorLevel, lines[0].Level)
+		assert.Equal(t, true, lines[0].ContextMap()["client_disconnected"])
+		assert.NotContains(t, lines[1].ContextMap(), "client_disconnected")
+	})
+
+	t.Run("Should lower the level of canceled requests", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithClientDisconnectedLevel(zapcore.WarnLevel),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			<-req.Context().Done()
+			if req.URL.Path == "/ok" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil).WithContext(ctx))
+
+		// Server-side timeouts are not client disconnects.
+		ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+		lines := logs.All()
+		assert.Len(t, lines, 3)
+		assert.Equal(t, zapcore.WarnLevel, lines[0].Level)
+		assert.Equal(t, true, lines[0].ContextMap()["client_disconnected"])
+		// The level is never raised.
+		assert.Equal(t, zapcore.InfoLevel, lines[1].Level)
+		assert.Equal(t, true, lines[1].ContextMap()["client_disconnected"])
+		assert.Equal(t, zapcore.ErrorLevel, lines[2].Level)
+		assert.NotContains(t, lines[2].ContextMap(), "client_disconnected")
+	})
+}
+
+func TestWithResponseHeaderFields(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithResponseHeaderFields(map[string]string{
+			"X-Cache":   "cache_status",
+			"X-Missing": "missing",
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Cache", "HIT")
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "HIT", lines[0].ContextMap()["cache_status"])
+	assert.NotContains(t, lines[0].ContextMap(), "missing")
+}
+
+func TestWithRequestHeaderFields(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithRequestHeaderFields(map[string]string{
+			"X-Tenant-ID":   "tenant_id",
+			"authorization": "auth",
+			"Cookie":        "cookie",
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		zaphttp.FromContext(req.Context()).Info("handling request")
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Equal(t, "acme", line.ContextMap()["tenant_id"])
+		assert.NotContains(t, line.ContextMap(), "auth")
+		assert.NotContains(t, line.ContextMap(), "cookie")
+	}
+}
+
+func TestWithSlowRequestThreshold(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithSlowRequestThreshold(20*time.Millisecond, zapcore.WarnLevel),
+		zaphttp.WithLevelFunc(func(statusCode int) zapcore.Level {
+			if statusCode == http.StatusAccepted {
+				return zapcore.ErrorLevel
+			}
+			return zaphttp.DefaultLevelFunc(statusCode)
+		}),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has("slow") {
+			time.Sleep(30 * time.Millisecond)
+		}
+		if req.URL.Query().Has("accepted") {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, target := range []string{"/", "/?slow", "/?slow&accepted"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	lines := logs.All()
+	assert.Len(t, lines, 3)
+
+	assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+	assert.NotContains(t, lines[0].ContextMap(), "slow")
+
+	assert.Equal(t, zapcore.WarnLevel, lines[1].Level)
+	assert.Equal(t, true, lines[1].ContextMap()["slow"])
+
+	// The level function picked a higher level, which should be kept.
+	assert.Equal(t, zapcore.ErrorLevel, lines[2].Level)
+	assert.Equal(t, true, lines[2].ContextMap()["slow"])
+}
+
+func TestWithOnlyLogPaths(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithOnlyLogPaths("/api/", "/static/*.css"),
+		zaphttp.WithPerRequestFilter(func(req *http.Request, _ zapcore.Level) bool {
+			return req.URL.Path != "/api/healthz"
+		}),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, target := range []string{
+		"/api/users",
+		"/api/users/1",
+		"/api/healthz",
+		"/",
+		"/apiv2/users",
+		"/static/main.css",
+		"/static/main.js",
+		"/static/css/main.css",
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	var paths []string
+	for _, line := range logs.All() {
+		urlMap, ok := line.ContextMap()["url"].(map[string]interface{})
+		if assert.True(t, ok, "url field should be a map") {
+			paths = append(paths, fmt.Sprint(urlMap["path"]))
+		}
+	}
+	assert.Equal(t, []string{"/api/users", "/api/users/1", "/static/main.css"}, paths)
+}
+
+func TestWithContextFields(t *testing.T) {
+	t.Parallel()
+
+	type userKey struct{}
+	type tenantKey struct{}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithContextFields(func(ctx context.Context) []zap.Field {
+			var fields []zap.Field
+			if user, ok := ctx.Value(userKey{}).(string); ok {
+				fields = append(fields, zap.String("user.id", user))
+			}
+			if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+				fields = append(fields, zap.String("tenant_id", tenant))
+			}
+			return fields
+		}),
+	)
+
+	// Auth middleware wrapping the zaphttp handler.
+	authMiddleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := context.WithValue(req.Context(), userKey{}, "user-1")
+			ctx = context.WithValue(ctx, tenantKey{}, "acme")
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+
+	handler := authMiddleware(requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		zaphttp.FromContext(req.Context()).Info("handling request")
+		w.WriteHeader(http.StatusOK)
+	})))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Equal(t, "user-1", line.ContextMap()["user.id"])
+		assert.Equal(t, "acme", line.ContextMap()["tenant_id"])
+	}
+}
+
+func TestWithSortedFields(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithSortedFields(),
+		zaphttp.WithRequestFormatter(zaphttp.NewFlatFormatter()),
+		zaphttp.WithResponseHeaderFields(map[string]string{"X-Cache": "cache"}),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Cache", "HIT")
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?a=1", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+
+	keys := make([]string, 0, len(lines[0].Context))
+	for _, field := range lines[0].Context {
+		keys = append(keys, field.Key)
+	}
+	assert.Greater(t, len(keys), 1)
+	assert.IsIncreasing(t, keys)
+}
+
+func TestWithResponseBodyLineCount(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithResponseBodyLineCount(),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		_, _ = w.Write([]byte("first line\nsecond line\n"))
+		_, _ = w.Write([]byte("third line\n"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/text", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/json", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, int64(3), lines[0].ContextMap()["response.line_count"])
+	assert.NotContains(t, lines[1].ContextMap(), "response.line_count")
+}
+
+func TestWithEventID(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithEventID(),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "Received HTTP request", lines[0].Message)
+	assert.Equal(t, "HTTP request finished", lines[1].Message)
+
+	first, ok := lines[0].ContextMap()["event_id"].(string)
+	assert.True(t, ok, "event_id should be a string")
+	assert.NotEmpty(t, first)
+	assert.Equal(t, first, lines[1].ContextMap()["event_id"])
+
+	second := lines[2].ContextMap()["event_id"]
+	assert.Equal(t, second, lines[3].ContextMap()["event_id"])
+	assert.NotEqual(t, first, second)
+}
+
+func TestWithStartMessage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		level    zapcore.Level
+		expected []string
+	}{
+		{
+			name:     "Info level",
+			enabled:  true,
+			level:    zapcore.InfoLevel,
+			expected: []string{"Received HTTP request", "HTTP request finished"},
+		},
+		{
+			name:     "Debug level is filtered by the logger",
+			enabled:  true,
+			level:    zapcore.DebugLevel,
+			expected: []string{"HTTP request finished"},
+		},
+		{
+			name:     "Disabled",
+			enabled:  false,
+			level:    zapcore.InfoLevel,
+			expected: []string{"HTTP request finished"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithStartMessage(tt.enabled, tt.level),
+			)
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			var messages []string
+			for _, line := range logs.All() {
+				messages = append(messages, line.Message)
+			}
+			assert.Equal(t, tt.expected, messages)
+		})
+	}
+}
+
+func TestWithAuthority(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithAuthority(),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "backend.internal:8080"
+	req.Header.Set("X-Forwarded-Host", "www.example.com")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "backend.internal:8080", lines[0].ContextMap()["http.authority"])
+	assert.Equal(t, "www.example.com", lines[0].ContextMap()["http.forwarded_host"])
+	assert.Equal(t, "example.com", lines[1].ContextMap()["http.authority"])
+	assert.NotContains(t, lines[1].ContextMap(), "http.forwarded_host")
+}
+
+func TestWithoutTracing(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithoutTracing(),
+		zaphttp.WithTraceIDResponseHeader("X-Trace-ID"),
+	)
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+		SpanID:     trace.SpanID{43, 21},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	assert.Len(t, lines, 1)
+	assert.NotContains(t, lines[0].ContextMap(), "trace")
+	assert.NotContains(t, lines[0].ContextMap(), "span")
+	assert.Empty(t, rec.Header().Get("X-Trace-ID"))
+}
+
+func TestWithFieldNamespace(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should nest the request fields", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRequestFormatter(zaphttp.NewLogstashFormatter()),
+			zaphttp.WithFieldNamespace("zaphttp"),
+			zaphttp.WithRequestID("X-Request-ID", func() string { return "request-1" }),
+		)
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+
+		fields := lines[0].ContextMap()
+		assert.Equal(t, "request-1", fields["request_id"])
+		namespace, ok := fields["zaphttp"].(map[string]interface{})
+		assert.True(t, ok, "zaphttp field should be a map")
+		assert.Equal(t, int64(http.StatusOK), namespace["status"])
+		assert.Equal(t, "/hello", namespace["path"])
+		assert.NotContains(t, fields, "status")
+	})
+
+	t.Run("Should not nest the fields when the name is empty", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRequestFormatter(zaphttp.NewLogstashFormatter()),
+			zaphttp.WithFieldNamespace(""),
+		)
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, int64(http.StatusOK), lines[0].ContextMap()["status"])
+	})
+}
+
+func TestWithErrorResponseBody(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithErrorResponseBody(16, http.StatusInternalServerError),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("database "))
+			_, _ = w.Write([]byte("connection refused"))
+		case "/short":
+			http.Error(w, "timeout", http.StatusBadGateway)
+		default:
+			_, _ = w.Write([]byte("everything is fine"))
+		}
+	}))
+
+	responses := make([]string, 0, 3)
+	for _, path := range []string{"/error", "/short", "/"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		responses = append(responses, rec.Body.String())
+	}
+
+	// The capture must not change the response.
+	assert.Equal(t, []string{"database connection refused", "timeout\n", "everything is fine"}, responses)
+
+	lines := logs.All()
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "database connect", lines[0].ContextMap()["response.body"])
+	assert.Equal(t, true, lines[0].ContextMap()["response.body_truncated"])
+	assert.Equal(t, "timeout\n", lines[1].ContextMap()["response.body"])
+	assert.Equal(t, false, lines[1].ContextMap()["response.body_truncated"])
+	assert.NotContains(t, lines[2].ContextMap(), "response.body")
+}
+
+func TestWithServerAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		formatter zaphttp.RequestFormatter
+		field     func(fields map[string]interface{}) interface{}
+	}{
+		{
+			name:      "Elastic Common Schema",
+			formatter: zaphttp.ElasticCommonSchemaFormatter,
+			field: func(fields map[string]interface{}) interface{} {
+				server, _ := fields["server"].(map[string]interface{})
+				return server["address"]
+			},
+		},
+		{
+			name:      "Google Cloud",
+			formatter: zaphttp.NewGoogleCloudFormatter("project"),
+			field: func(fields map[string]interface{}) interface{} {
+				httpRequest, _ := fields["httpRequest"].(map[string]interface{})
+				return httpRequest["serverIp"]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithRequestFormatter(tt.formatter),
+				zaphttp.WithServerAddress(func(req *http.Request) string {
+					if req.URL.Path == "/fallback" {
+						return ""
+					}
+					return "10.0.0.1:8080"
+				}),
+			)
+			handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/fallback", nil)
+			localAddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9090}
+			req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, localAddr))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			lines := logs.All()
+			assert.Len(t, lines, 2)
+			assert.Equal(t, "10.0.0.1:8080", tt.field(lines[0].ContextMap()))
+			assert.Equal(t, "127.0.0.1:9090", tt.field(lines[1].ContextMap()))
+		})
+	}
+}
+
+func TestWithImplicitStatusField(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithImplicitStatusField(),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/explicit" {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/explicit", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+	assert.Equal(t, true, lines[0].ContextMap()["implicit_status"])
+	assert.NotContains(t, lines[1].ContextMap(), "implicit_status")
+}
+
+func TestWithInitialFields(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithPerRequestLogger(func(parent *zap.Logger, _ *http.Request) *zap.Logger {
+			return parent.With(zap.String("custom", "value"))
+		}),
+		zaphttp.WithInitialFields(zap.String("service.name", "api")),
+		zaphttp.WithInitialFields(zap.String("service.version", "1.2.3")),
+	)
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := logs.All()
+	assert.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Equal(t, "value", line.ContextMap()["custom"])
+		assert.Equal(t, "api", line.ContextMap()["service.name"])
+		assert.Equal(t, "1.2.3", line.ContextMap()["service.version"])
+	}
+}
@@ -50,6 +50,56 @@ func TestNewHandler(t *testing.T) {
 		assert.Equal(t, "HTTP request finished", lines[1].Message)
 	})
 
+	t.Run("Should log the time spent building the logger on the start line", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.DebugLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 2)
+
+		buildTime, ok := lines[0].ContextMap()["logger_build_us"].(int64)
+		assert.True(t, ok, "logger_build_us should be an int64")
+		assert.GreaterOrEqual(t, buildTime, int64(0))
+		assert.NotContains(t, lines[1].ContextMap(), "logger_build_us")
+	})
+
+	t.Run("Should log the Content-Language sent in the response", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", "fr, en;q=0.5")
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Language", "fr")
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "fr", lines[0].ContextMap()["response.language"])
+	})
+
 	t.Run("Emit the right log line for each status code", func(t *testing.T) {
 		t.Parallel()
 
@@ -298,6 +348,39 @@ func TestNewHandler(t *testing.T) {
 		assert.Equal(t, "application/json", responseMap["mime_type"])
 	})
 
+	t.Run("Should log a 200 OK if the handler did not write a response", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+		assert.Equal(t, "HTTP request finished", lines[0].Message)
+
+		httpMap, ok := lines[0].ContextMap()["http"].(map[string]interface{})
+		assert.True(t, ok, "http field should be a map")
+
+		responseMap, ok := httpMap["response"].(map[string]interface{})
+		assert.True(t, ok, "response field should be a map")
+
+		assert.Equal(t, 200, responseMap["status_code"])
+		assert.Equal(t, "application/json", responseMap["mime_type"])
+		assert.NotContains(t, lines[0].ContextMap(), "implicit_status")
+	})
+
 	t.Run("Check custom per request filter function", func(t *testing.T) {
 		t.Parallel()
 
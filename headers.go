@@ -0,0 +1,67 @@
+package zaphttp
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// sensitiveHeaders contains the canonical names of request headers that commonly contain credentials. They are never
+// logged using WithRequestHeaderFields.
+var sensitiveHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Proxy-Authorization": {},
+	"Cookie":              {},
+	"X-Api-Key":           {},
+	"X-Auth-Token":        {},
+	"X-Csrf-Token":        {},
+	"X-Xsrf-Token":        {},
+}
+
+// isSensitiveHeader returns true if the header commonly contains credentials.
+func isSensitiveHeader(name string) bool {
+	_, ok := sensitiveHeaders[http.CanonicalHeaderKey(name)]
+	return ok
+}
+
+// captureHeaders returns the values of the named headers, keyed by the lowercased header name. Multiple values of the
+// same header are joined with commas. Headers that are not present are skipped, nil is returned if none of the headers
+// are present.
+func captureHeaders(header http.Header, names []string) map[string]string {
+	var captured map[string]string
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if captured == nil {
+			captured = make(map[string]string, len(names))
+		}
+		captured[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	return captured
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// headerFields returns a field for each header in mapping (header name to field name) that is present in header.
+// Multiple values of the same header are joined with commas. Fields are sorted by header name.
+func headerFields(header http.Header, mapping map[string]string) []zap.Field {
+	var fields []zap.Field
+	for _, name := range sortedKeys(mapping) {
+		if values := header.Values(name); len(values) > 0 {
+			fields = append(fields, zap.String(mapping[name], strings.Join(values, ",")))
+		}
+	}
+	return fields
+}
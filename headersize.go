@@ -0,0 +1,14 @@
+package zaphttp
+
+import "net/http"
+
+// headerSize returns the size of the headers as they would be sent using HTTP/1.1 ("Name: value\r\n" per value).
+func headerSize(header http.Header) int {
+	var size int
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(value) + len(": \r\n")
+		}
+	}
+	return size
+}
@@ -0,0 +1,131 @@
+package zaphttp
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyHistogramBuckets are the bucket upper bounds used by WithLatencyHistogram when no buckets are supplied.
+var DefaultLatencyHistogramBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// HistogramBucket is a single bucket of a HistogramSnapshot.
+type HistogramBucket struct {
+	// UpperBound is the inclusive upper bound of the bucket.
+	UpperBound time.Duration
+	// Count is the number of observations with a latency less than or equal to UpperBound. Buckets are cumulative.
+	Count uint64
+}
+
+// HistogramSnapshot is a point in time copy of a latency histogram.
+type HistogramSnapshot struct {
+	// Buckets contains the cumulative bucket counts, sorted by upper bound.
+	Buckets []HistogramBucket
+	// Count is the total number of observations, including the ones that are larger than the largest bucket.
+	Count uint64
+	// Sum is the sum of all observed latencies.
+	Sum time.Duration
+}
+
+type latencyHistogram struct {
+	bounds []time.Duration
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Int64
+}
+
+func (h *latencyHistogram) Observe(latency time.Duration) {
+	// Only increment the first matching bucket, the snapshot makes the buckets cumulative.
+	if i, _ := slices.BinarySearch(h.bounds, latency); i < len(h.bounds) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	h.sum.Add(int64(latency))
+}
+
+func (h *latencyHistogram) Snapshot() HistogramSnapshot {
+	snapshot := HistogramSnapshot{
+		Buckets: make([]HistogramBucket, len(h.bounds)),
+		Count:   h.count.Load(),
+		Sum:     time.Duration(h.sum.Load()),
+	}
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i].Load()
+		snapshot.Buckets[i] = HistogramBucket{UpperBound: bound, Count: cumulative}
+	}
+	return snapshot
+}
+
+// latencyHistogramSet keeps a latency histogram per route.
+type latencyHistogramSet struct {
+	bounds []time.Duration
+
+	mu     sync.RWMutex
+	routes map[string]*latencyHistogram
+}
+
+func newLatencyHistogramSet(buckets []time.Duration) *latencyHistogramSet {
+	bounds := slices.Clone(buckets)
+	slices.Sort(bounds)
+	return &latencyHistogramSet{
+		bounds: slices.Compact(bounds),
+		routes: make(map[string]*latencyHistogram),
+	}
+}
+
+func (s *latencyHistogramSet) Observe(route string, latency time.Duration) {
+	s.mu.RLock()
+	h, ok := s.routes[route]
+	s.mu.RUnlock()
+
+	if !ok {
+		s.mu.Lock()
+		if h, ok = s.routes[route]; !ok {
+			h = &latencyHistogram{
+				bounds: s.bounds,
+				counts: make([]atomic.Uint64, len(s.bounds)),
+			}
+			s.routes[route] = h
+		}
+		s.mu.Unlock()
+	}
+
+	h.Observe(latency)
+}
+
+func (s *latencyHistogramSet) Snapshot() map[string]HistogramSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshots := make(map[string]HistogramSnapshot, len(s.routes))
+	for route, h := range s.routes {
+		snapshots[route] = h.Snapshot()
+	}
+	return snapshots
+}
+
+// LatencyHistogram returns a snapshot of the latency histograms collected by the handler, keyed by the route pattern
+// (see WithRoutePatternFunc). Requests that did not match a route are grouped under an empty string. Requests that
+// panicked are only included if the panic was recovered using WithRecover. Returns nil if WithLatencyHistogram is not
+// used.
+func (h *Handler) LatencyHistogram() map[string]HistogramSnapshot {
+	if h.latencyHistogram == nil {
+		return nil
+	}
+	return h.latencyHistogram.Snapshot()
+}
@@ -0,0 +1,95 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHandler_LatencyHistogram(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should return nil if histograms are not enabled", func(t *testing.T) {
+		t.Parallel()
+
+		h := zaphttp.New(zaphttp.WithLogger(zap.NewNop()))
+		assert.Nil(t, h.LatencyHistogram())
+	})
+
+	t.Run("Should count latencies per route", func(t *testing.T) {
+		t.Parallel()
+
+		h := zaphttp.New(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithLatencyHistogram(time.Second, 50*time.Millisecond),
+		)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/fast", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, _ *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		})
+		handler := h.Wrap(mux)
+
+		for _, path := range []string{"/fast", "/fast", "/slow"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+		}
+
+		histograms := h.LatencyHistogram()
+		assert.Len(t, histograms, 2)
+
+		fast := histograms["/fast"]
+		assert.Equal(t, uint64(2), fast.Count)
+		assert.Equal(t, []zaphttp.HistogramBucket{
+			{UpperBound: 50 * time.Millisecond, Count: 2},
+			{UpperBound: time.Second, Count: 2},
+		}, fast.Buckets)
+
+		slow := histograms["/slow"]
+		assert.Equal(t, uint64(1), slow.Count)
+		assert.GreaterOrEqual(t, slow.Sum, 100*time.Millisecond)
+		assert.Equal(t, []zaphttp.HistogramBucket{
+			{UpperBound: 50 * time.Millisecond, Count: 0},
+			{UpperBound: time.Second, Count: 1},
+		}, slow.Buckets)
+	})
+
+	t.Run("Should use the route pattern function", func(t *testing.T) {
+		t.Parallel()
+
+		h := zaphttp.New(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithLatencyHistogram(time.Second),
+			zaphttp.WithRoutePatternFunc(func(req *http.Request) string {
+				if strings.HasPrefix(req.URL.Path, "/users/") {
+					return "/users/:id"
+				}
+				return ""
+			}),
+		)
+		handler := h.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		for _, path := range []string{"/users/1", "/users/2", "/unknown/1", "/unknown/2"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		histograms := h.LatencyHistogram()
+		assert.Len(t, histograms, 2)
+		assert.Equal(t, uint64(2), histograms["/users/:id"].Count)
+		// Requests that did not match a route share one histogram.
+		assert.Equal(t, uint64(2), histograms[""].Count)
+	})
+}
@@ -0,0 +1,44 @@
+package zaphttp
+
+// Messages contains the messages used for the log lines written by the handler.
+type Messages struct {
+	// Start is used for the debug log line written when a request is received.
+	Start string
+	// Finished is used for requests that completed with a 1xx, 2xx or 3xx status code.
+	Finished string
+	// ClientError is used for requests that completed with a 4xx status code.
+	ClientError string
+	// ServerError is used for requests that completed with a 5xx (or unknown) status code.
+	ServerError string
+	// Panicked is used for requests where the handler panicked.
+	Panicked string
+	// Hijacked is used for the log line written when the connection is hijacked, see WithLogRequestEvenOnEarlyHijack.
+	Hijacked string
+}
+
+// DefaultMessages are the messages used by the handler if WithMessages is not used.
+var DefaultMessages = Messages{
+	Start:       "Received HTTP request",
+	Finished:    "HTTP request finished",
+	ClientError: "HTTP request failed due to a client error",
+	ServerError: "HTTP request failed",
+	Panicked:    "HTTP request panicked",
+	Hijacked:    "HTTP request hijacked",
+}
+
+// merge returns a copy of m where all non-empty messages are replaced by the ones in overrides.
+func (m Messages) merge(overrides Messages) Messages {
+	replace := func(current *string, override string) {
+		if override != "" {
+			*current = override
+		}
+	}
+
+	replace(&m.Start, overrides.Start)
+	replace(&m.Finished, overrides.Finished)
+	replace(&m.ClientError, overrides.ClientError)
+	replace(&m.ServerError, overrides.ServerError)
+	replace(&m.Panicked, overrides.Panicked)
+	replace(&m.Hijacked, overrides.Hijacked)
+	return m
+}
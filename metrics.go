@@ -0,0 +1,11 @@
+package zaphttp
+
+import "net/http"
+
+// MetricsRecorder records metrics about requests, use WithMetricsRecorder to enable it.
+type MetricsRecorder interface {
+	// RecordRequest is called once the handler is done with a request. Route is the route pattern returned by the
+	// RoutePatternFunc (for example "/users/{id}"), or an empty string if no route matched.
+	// ResponseInfo.Panicked is set if the handler panicked.
+	RecordRequest(req *http.Request, route string, res *ResponseInfo)
+}
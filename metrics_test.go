@@ -0,0 +1,48 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type routeRecorder struct {
+	mu     sync.Mutex
+	routes []string
+}
+
+func (r *routeRecorder) RecordRequest(_ *http.Request, route string, _ *zaphttp.ResponseInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route)
+}
+
+func TestWithMetricsRecorder(t *testing.T) {
+	t.Parallel()
+
+	recorder := &routeRecorder{}
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(zap.NewNop()),
+		zaphttp.WithMetricsRecorder(recorder),
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("example.com/about", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := requestLogger(mux)
+
+	for _, target := range []string{"/users/5", "http://example.com/about", "/missing//page/../other"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	assert.Equal(t, []string{"/users/{id}", "/about", ""}, recorder.routes)
+}
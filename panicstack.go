@@ -0,0 +1,55 @@
+package zaphttp
+
+import (
+	"bufio"
+	"bytes"
+	"hash/fnv"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// stackArgsRegexp matches the arguments of a function in a stack trace, e.g. "(0xc000010000, 0x1)".
+	stackArgsRegexp = regexp.MustCompile(`\([^()]*\)$`)
+	// stackOffsetRegexp matches the program counter offset of a file line in a stack trace, e.g. " +0x1d".
+	stackOffsetRegexp = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+	// stackGoroutineRegexp matches goroutine IDs in stack traces, e.g. "in goroutine 6".
+	stackGoroutineRegexp = regexp.MustCompile(` in goroutine \d+$`)
+)
+
+// panicStackFields captures the stack of the current goroutine. This must be called from the deferred function that
+// runs while the goroutine is panicking, the stack still contains the frames that caused the panic at that point.
+func panicStackFields() []zap.Field {
+	stack := debug.Stack()
+	return []zap.Field{
+		zap.ByteString("panic.stack_trace", stack),
+		zap.String("panic.stack_hash", stackHash(stack)),
+	}
+}
+
+// stackHash returns a hash of the logical location of a stack trace. Goroutine IDs, function arguments and program
+// counter offsets are stripped, so panics from the same location result in the same hash.
+func stackHash(stack []byte) string {
+	h := fnv.New64a()
+
+	scanner := bufio.NewScanner(bytes.NewReader(stack))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "goroutine ") {
+			// Header line, e.g. "goroutine 7 [running]:".
+			continue
+		}
+
+		line = stackArgsRegexp.ReplaceAllString(line, "")
+		line = stackOffsetRegexp.ReplaceAllString(line, "")
+		line = stackGoroutineRegexp.ReplaceAllString(line, "")
+		_, _ = h.Write([]byte(line))
+		_, _ = h.Write([]byte{'\n'})
+	}
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,50 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithPanicStackTrace(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithPanicStackTrace(),
+	)
+
+	handler := requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/other" {
+			panic("other location")
+		}
+		panic("same location")
+	}))
+
+	for _, path := range []string{"/", "/", "/other"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		assert.Panics(t, func() {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+
+	lines := logs.All()
+	require.Len(t, lines, 3)
+
+	for _, line := range lines {
+		assert.Contains(t, line.ContextMap()["panic.stack_trace"], "panicstack_test.go")
+		assert.NotEmpty(t, line.ContextMap()["panic.stack_hash"])
+	}
+	assert.Equal(t, lines[0].ContextMap()["panic.stack_hash"], lines[1].ContextMap()["panic.stack_hash"])
+	assert.NotEqual(t, lines[0].ContextMap()["panic.stack_hash"], lines[2].ContextMap()["panic.stack_hash"])
+}
@@ -0,0 +1,58 @@
+package zaphttp
+
+import (
+	"path"
+	"strings"
+)
+
+// normalizePath collapses repeated slashes and resolves "." and ".." segments. Unlike path.Clean, a trailing slash is
+// kept since it is significant for routing. The asterisk-form request target "*" of "OPTIONS *" is returned unchanged.
+func normalizePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p == "*" {
+		return p
+	}
+
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// pathMatcher matches request paths against prefix and glob patterns.
+type pathMatcher struct {
+	prefixes []string
+	globs    []string
+}
+
+// newPathMatcher creates a matcher for patterns. Patterns containing "*", "?" or "[" are glob patterns matched using
+// path.Match, all other patterns match paths that start with the pattern.
+func newPathMatcher(patterns []string) *pathMatcher {
+	m := &pathMatcher{}
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?[") {
+			m.globs = append(m.globs, pattern)
+		} else {
+			m.prefixes = append(m.prefixes, pattern)
+		}
+	}
+	return m
+}
+
+func (m *pathMatcher) Match(p string) bool {
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	for _, glob := range m.globs {
+		// Invalid patterns never match.
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+	}
+	return false
+}
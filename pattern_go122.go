@@ -0,0 +1,10 @@
+//go:build !go1.23
+
+package zaphttp
+
+import "net/http"
+
+// requestPattern returns an empty string, http.ServeMux only stores the matched pattern on the request since Go 1.23.
+func requestPattern(_ *http.Request) string {
+	return ""
+}
@@ -0,0 +1,14 @@
+package zaphttp
+
+import (
+	"net/http"
+)
+
+// alpnHTTP2 is the TLS ALPN protocol ID for HTTP/2.
+const alpnHTTP2 = "h2"
+
+// isProtocolDowngrade returns true if the client negotiated HTTP/2 during the TLS handshake, but the request was
+// served using an older HTTP version.
+func isProtocolDowngrade(req *http.Request) bool {
+	return req.TLS != nil && req.TLS.NegotiatedProtocol == alpnHTTP2 && req.ProtoMajor < 2
+}
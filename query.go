@@ -0,0 +1,56 @@
+package zaphttp
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redactedQueryValue replaces the values of query parameters configured using WithRedactedQueryParams.
+const redactedQueryValue = "REDACTED"
+
+// redactQuery replaces the values of the listed query parameters in rawQuery with redactedQueryValue. The order of
+// the parameters and the encoding of all other parameters is kept as is. Parameters with an empty value are not
+// changed, there is nothing to hide.
+func redactQuery(rawQuery string, params map[string]struct{}) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		rawKey, value, ok := strings.Cut(pair, "=")
+		if !ok || value == "" {
+			continue
+		}
+
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if _, redact := params[key]; redact {
+			pairs[i] = rawKey + "=" + redactedQueryValue
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactRequestQuery returns a shallow copy of req with the listed query parameters redacted in the URL. The original
+// request is not modified, so the handler still sees the original values. Returns req itself if nothing is redacted.
+func redactRequestQuery(req *http.Request, params map[string]struct{}) *http.Request {
+	if len(params) == 0 || req.URL == nil {
+		return req
+	}
+
+	rawQuery := redactQuery(req.URL.RawQuery, params)
+	if rawQuery == req.URL.RawQuery {
+		return req
+	}
+
+	u := *req.URL
+	u.RawQuery = rawQuery
+
+	redacted := req.WithContext(req.Context())
+	redacted.URL = &u
+	return redacted
+}
@@ -0,0 +1,84 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithRedactedQueryParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		target   string
+		expected string
+	}{
+		{
+			name:     "Single parameter",
+			target:   "/search?q=shoes&token=abc",
+			expected: "q=shoes&token=REDACTED",
+		},
+		{
+			name:     "Parameter that appears multiple times",
+			target:   "/search?api_key=one&q=shoes&api_key=two",
+			expected: "api_key=REDACTED&q=shoes&api_key=REDACTED",
+		},
+		{
+			name:     "Parameter with empty value",
+			target:   "/search?token=&q=shoes&token",
+			expected: "token=&q=shoes&token",
+		},
+		{
+			name:     "Encoded parameter name",
+			target:   "/search?%74oken=abc&q=a%26b",
+			expected: "%74oken=REDACTED&q=a%26b",
+		},
+		{
+			name:     "No sensitive parameters",
+			target:   "/search?q=shoes",
+			expected: "q=shoes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithRedactedQueryParams([]string{"token", "api_key"}),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			rec := httptest.NewRecorder()
+
+			var handlerQuery string
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				handlerQuery = req.URL.RawQuery
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			// The handler should still see the original values.
+			assert.Equal(t, req.URL.RawQuery, handlerQuery)
+
+			lines := logs.All()
+			require.Len(t, lines, 1)
+
+			urlMap, ok := lines[0].ContextMap()["url"].(map[string]interface{})
+			require.True(t, ok, "url field should be a map")
+			assert.Equal(t, tt.expected, urlMap["query"])
+			assert.Equal(t, "/search?"+tt.expected, urlMap["original"])
+		})
+	}
+}
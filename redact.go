@@ -0,0 +1,27 @@
+package zaphttp
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldRedactorFunc is called for every field of a request log line. It receives the field value as it would be
+// encoded (objects are passed as map[string]any) and returns the value that should be logged instead.
+type FieldRedactorFunc func(key string, value any) any
+
+// redactFields runs the redactor over all fields. Fields are encoded to a map first, so nested objects can be
+// inspected by the redactor.
+func redactFields(fields []zap.Field, redact FieldRedactorFunc) []zap.Field {
+	redacted := make([]zap.Field, 0, len(fields))
+	for _, f := range fields {
+		if f.Type == zapcore.SkipType || f.Type == zapcore.NamespaceType {
+			redacted = append(redacted, f)
+			continue
+		}
+
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		redacted = append(redacted, zap.Any(f.Key, redact(f.Key, enc.Fields[f.Key])))
+	}
+	return redacted
+}
@@ -0,0 +1,63 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// customFormatter adds fields from request headers.
+type customFormatter struct{}
+
+func (*customFormatter) GetTraceFields(_ *http.Request, _ trace.SpanContext) []zap.Field {
+	return nil
+}
+
+func (*customFormatter) GetRequestFields(req *http.Request, res *zaphttp.ResponseInfo) []zap.Field {
+	return []zap.Field{
+		zap.String("contact", req.Header.Get("X-Contact")),
+		zap.Int("status", res.StatusCode),
+	}
+}
+
+func TestWithFieldRedactor(t *testing.T) {
+	t.Parallel()
+
+	emailRegexp := regexp.MustCompile(`[^@\s]+@[^@\s]+\.[a-z]+`)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithRequestFormatter(&customFormatter{}),
+		zaphttp.WithFieldRedactor(func(_ string, value any) any {
+			if s, ok := value.(string); ok {
+				return emailRegexp.ReplaceAllString(s, "REDACTED")
+			}
+			return value
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Contact", "mail john@example.com please")
+	rec := httptest.NewRecorder()
+
+	requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rec, req)
+
+	lines := logs.All()
+	require.Len(t, lines, 1)
+	assert.Equal(t, "mail REDACTED please", lines[0].ContextMap()["contact"])
+	assert.Equal(t, int64(http.StatusOK), lines[0].ContextMap()["status"])
+}
@@ -0,0 +1,25 @@
+package zaphttp
+
+import (
+	"net"
+	"strconv"
+)
+
+// RemoteAddrParserFunc splits a remote address (req.RemoteAddr) into a host and port. Port should be 0 if the address
+// does not contain a port.
+type RemoteAddrParserFunc func(remoteAddr string) (host string, port int)
+
+// DefaultRemoteAddrParser splits "host:port" and "[ipv6]:port" addresses. Addresses that do not contain a port, like
+// Unix socket paths, are returned as host with port 0.
+func DefaultRemoteAddrParser(remoteAddr string) (string, int) {
+	host, rawPort, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr, 0
+	}
+
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		return host, 0
+	}
+	return host, port
+}
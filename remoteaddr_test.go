@@ -0,0 +1,107 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDefaultRemoteAddrParser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		remoteAddr string
+		host       string
+		port       int
+	}{
+		{"192.0.2.1:1234", "192.0.2.1", 1234},
+		{"[2001:db8::1]:8080", "2001:db8::1", 8080},
+		{"192.0.2.1", "192.0.2.1", 0},
+		{"@", "@", 0},
+		{"/var/run/app.sock", "/var/run/app.sock", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			t.Parallel()
+
+			host, port := zaphttp.DefaultRemoteAddrParser(tt.remoteAddr)
+			assert.Equal(t, tt.host, host)
+			assert.Equal(t, tt.port, port)
+		})
+	}
+}
+
+func TestWithRemoteAddrParser(t *testing.T) {
+	t.Parallel()
+
+	// Parse a PROXY protocol like address: "PROXY TCP4 <client ip> <proxy ip> <client port> <proxy port>".
+	proxyParser := func(remoteAddr string) (string, int) {
+		parts := strings.Fields(remoteAddr)
+		if len(parts) != 6 {
+			return remoteAddr, 0
+		}
+		port, _ := strconv.Atoi(parts[4])
+		return parts[2], port
+	}
+
+	tests := []struct {
+		name       string
+		parser     zaphttp.RemoteAddrParserFunc
+		remoteAddr string
+		expected   map[string]interface{}
+	}{
+		{
+			name:       "Default IPv4",
+			remoteAddr: "192.0.2.1:1234",
+			expected:   map[string]interface{}{"address": "192.0.2.1", "port": 1234},
+		},
+		{
+			name:       "Default IPv6",
+			remoteAddr: "[2001:db8::1]:8080",
+			expected:   map[string]interface{}{"address": "2001:db8::1", "port": 8080},
+		},
+		{
+			name:       "Custom format",
+			parser:     proxyParser,
+			remoteAddr: "PROXY TCP4 198.51.100.22 203.0.113.7 35646 80",
+			expected:   map[string]interface{}{"address": "198.51.100.22", "port": 35646},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			opts := []zaphttp.HandlerOption{zaphttp.WithLogger(logger)}
+			if tt.parser != nil {
+				opts = append(opts, zaphttp.WithRemoteAddrParser(tt.parser))
+			}
+			requestLogger := zaphttp.NewHandler(opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			lines := logs.All()
+			require.Len(t, lines, 1)
+			assert.Equal(t, tt.expected, lines[0].ContextMap()["client"])
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package zaphttp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// maxRequestIDLength is the maximum length of a request ID supplied by the client. Longer IDs are replaced by a
+// generated one, so a client cannot inflate every log line of the request.
+const maxRequestIDLength = 128
+
+// isValidRequestID returns true if the request ID supplied by the client is short enough and only contains characters
+// that are safe to log and echo in a response header: letters, digits and "-._~:/+=".
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("-._~:/+=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID generates a random request ID formatted like a version 4 UUID. It is the default generator used by
+// WithRequestID.
+func NewRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:]) // crypto/rand.Read never returns an error.
+
+	id[6] = (id[6] & 0x0f) | 0x40 // Version 4.
+	id[8] = (id[8] & 0x3f) | 0x80 // RFC 4122 variant.
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+	return string(buf[:])
+}
@@ -0,0 +1,120 @@
+package zaphttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewRequestID(t *testing.T) {
+	t.Parallel()
+
+	id := zaphttp.NewRequestID()
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	assert.NotEqual(t, id, zaphttp.NewRequestID())
+}
+
+func TestWithRequestID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		header   []string
+		expected string
+	}{
+		{name: "Incoming request ID", header: []string{"abc-123"}, expected: "abc-123"},
+		{name: "Missing request ID", expected: "generated"},
+		{name: "Empty request ID", header: []string{""}, expected: "generated"},
+		{name: "Request ID with allowed punctuation", header: []string{"a.b_c~d:e/f+g=h"}, expected: "a.b_c~d:e/f+g=h"},
+		{name: "Request ID at maximum length", header: []string{strings.Repeat("a", 128)}, expected: strings.Repeat("a", 128)},
+		{name: "Too long request ID", header: []string{strings.Repeat("a", 129)}, expected: "generated"},
+		{name: "Request ID with newline", header: []string{"abc\nfake log line"}, expected: "generated"},
+		{name: "Request ID with space", header: []string{"abc 123"}, expected: "generated"},
+		{name: "Request ID with quote", header: []string{`abc"123`}, expected: "generated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithRequestID("X-Request-ID", func() string { return "generated" }),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != nil {
+				req.Header["X-Request-Id"] = tt.header
+			}
+			rec := httptest.NewRecorder()
+
+			var fromContext string
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				fromContext = zaphttp.RequestIDFromContext(req.Context())
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, fromContext)
+			assert.Equal(t, tt.expected, rec.Header().Get("X-Request-ID"))
+
+			lines := logs.All()
+			assert.Len(t, lines, 1)
+			assert.Equal(t, tt.expected, lines[0].ContextMap()["request_id"])
+		})
+	}
+
+	t.Run("Should fall back to the trace ID", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRequestID("X-Request-ID", func() string { return "generated" }),
+			zaphttp.WithRequestIDFromTrace(),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{12, 34, 56, 78, 90},
+			SpanID:     trace.SpanID{43, 21},
+			TraceFlags: trace.FlagsSampled,
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(trace.ContextWithSpanContext(req.Context(), spanCtx))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, "0c22384e5a0000000000000000000000", rec.Header().Get("X-Request-ID"))
+
+		// Without span an ID is generated.
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, "generated", rec.Header().Get("X-Request-ID"))
+
+		lines := logs.All()
+		assert.Len(t, lines, 2)
+		assert.Equal(t, "0c22384e5a0000000000000000000000", lines[0].ContextMap()["request_id"])
+		assert.Equal(t, "generated", lines[1].ContextMap()["request_id"])
+	})
+
+	t.Run("Should return an empty ID outside a request", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Empty(t, zaphttp.RequestIDFromContext(context.Background()))
+	})
+}
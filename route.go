@@ -0,0 +1,27 @@
+package zaphttp
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RoutePatternFunc returns the route pattern that matched the request, for example "/users/{id}". It is called after
+// the next handler returned, so routers have had the chance to store the matched route. Returns an empty string if no
+// route matched.
+type RoutePatternFunc func(req *http.Request) string
+
+// DefaultRoutePatternFunc returns the path template of the pattern matched by http.ServeMux, without the method and
+// host. For example "/users/{id}" for the pattern "GET example.com/users/{id}". Returns an empty string when built with
+// Go 1.22, because http.ServeMux only stores the matched pattern on the request since Go 1.23.
+func DefaultRoutePatternFunc(req *http.Request) string {
+	pattern := requestPattern(req)
+
+	// Patterns have the form "[METHOD ][HOST]/[PATH]".
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
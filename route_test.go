@@ -0,0 +1,91 @@
+//go:build go1.23
+
+package zaphttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDefaultRoutePatternFunc(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"":                        "",
+		"/":                       "/",
+		"/users/{id}":             "/users/{id}",
+		"GET /users/{id}":         "/users/{id}",
+		"GET example.com/users/":  "/users/",
+		"example.com/{path...}":   "/{path...}",
+		"POST  /files/{name}/{$}": "/files/{name}/{$}",
+	}
+
+	for pattern, expected := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Pattern = pattern
+		assert.Equal(t, expected, zaphttp.DefaultRoutePatternFunc(req), pattern)
+	}
+}
+
+func TestRouteField(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should log the ServeMux pattern", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		requestLogger := zaphttp.NewHandler(zaphttp.WithLogger(zap.New(core)))
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		handler := requestLogger(mux)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/5", nil))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+		lines := logs.All()
+		require.Len(t, lines, 2)
+		assert.Equal(t, "/users/{id}", lines[0].ContextMap()["http.route"])
+		assert.NotContains(t, lines[1].ContextMap(), "http.route")
+	})
+
+	t.Run("Should use the route pattern function", func(t *testing.T) {
+		t.Parallel()
+
+		type routeKey struct{}
+		core, logs := observer.New(zapcore.InfoLevel)
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.New(core)),
+			zaphttp.WithRoutePatternFunc(func(req *http.Request) string {
+				// Third-party routers store the matched route in a mutable value in the request context.
+				route, _ := req.Context().Value(routeKey{}).(*string)
+				return *route
+			}),
+		)
+
+		route := new(string)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			*route = "/orders/:id"
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		req = req.WithContext(context.WithValue(req.Context(), routeKey{}, route))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.Equal(t, "/orders/:id", lines[0].ContextMap()["http.route"])
+	})
+}
@@ -0,0 +1,40 @@
+package zaphttp
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// runtimeStatsMaxAge is the maximum age of a cached runtime stats snapshot.
+// runtime.ReadMemStats stops the world, reading it for every failing request during an error spike would only make
+// things worse. Snapshots are therefore shared between requests for this duration.
+const runtimeStatsMaxAge = time.Second
+
+// runtimeStatsSampler lazily reads Go runtime stats and caches them for a short amount of time.
+type runtimeStatsSampler struct {
+	mu       sync.Mutex
+	readAt   time.Time
+	memStats runtime.MemStats
+}
+
+func (s *runtimeStatsSampler) Fields() []zap.Field {
+	s.mu.Lock()
+	if now := time.Now(); now.Sub(s.readAt) > runtimeStatsMaxAge {
+		runtime.ReadMemStats(&s.memStats)
+		s.readAt = now
+	}
+	heapInUse := s.memStats.HeapInuse
+	heapAlloc := s.memStats.HeapAlloc
+	numGC := s.memStats.NumGC
+	s.mu.Unlock()
+
+	return []zap.Field{
+		zap.Uint64("runtime.heap_inuse_bytes", heapInUse),
+		zap.Uint64("runtime.heap_alloc_bytes", heapAlloc),
+		zap.Uint32("runtime.num_gc", numGC),
+		zap.Int("runtime.goroutines", runtime.NumGoroutine()),
+	}
+}
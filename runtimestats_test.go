@@ -0,0 +1,102 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithRuntimeStatsOnError(t *testing.T) {
+	t.Parallel()
+
+	runtimeFields := []string{
+		"runtime.heap_inuse_bytes",
+		"runtime.heap_alloc_bytes",
+		"runtime.num_gc",
+		"runtime.goroutines",
+	}
+
+	t.Run("Should add runtime stats to server errors", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRuntimeStatsOnError(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		for _, field := range runtimeFields {
+			assert.Contains(t, lines[0].ContextMap(), field)
+		}
+		assert.Positive(t, lines[0].ContextMap()["runtime.goroutines"])
+	})
+
+	t.Run("Should add runtime stats to panics", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRuntimeStatsOnError(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		assert.Panics(t, func() {
+			requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				panic("broken")
+			})).ServeHTTP(rec, req)
+		})
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		for _, field := range runtimeFields {
+			assert.Contains(t, lines[0].ContextMap(), field)
+		}
+	})
+
+	t.Run("Should not add runtime stats to successful requests", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithRuntimeStatsOnError(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, req)
+
+		lines := logs.All()
+		assert.Len(t, lines, 1)
+		for _, field := range runtimeFields {
+			assert.NotContains(t, lines[0].ContextMap(), field)
+		}
+	})
+}
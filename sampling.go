@@ -0,0 +1,153 @@
+package zaphttp
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sampleStatusClass decides if a request with the status code should be logged, based on the sample rate configured
+// for its status class. Status classes without a configured rate are always logged.
+func sampleStatusClass(rates map[int]float64, statusCode int) bool {
+	rate, ok := rates[statusCode/100]
+	if !ok || rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	//nolint:gosec // Sampling does not need a cryptographically secure random number generator.
+	return rand.Float64() < rate
+}
+
+// samplingTick is the interval after which the counts of the requestSampler are reset, the same as zap.Config uses.
+const samplingTick = time.Second
+
+// requestSampler samples requests per key like the zap sampler: every tick, the first initial requests with the same
+// key are logged, and every thereafter-th request after that.
+type requestSampler struct {
+	initial    uint64
+	thereafter uint64
+
+	mu sync.Mutex
+	// resetAt is the time at which the current tick ends.
+	resetAt time.Time
+	counts  map[string]uint64
+}
+
+func newRequestSampler(initial, thereafter int) *requestSampler {
+	return &requestSampler{
+		initial:    uint64(max(initial, 0)),
+		thereafter: uint64(max(thereafter, 0)),
+		counts:     make(map[string]uint64),
+	}
+}
+
+// Sample returns true if the request with the key should be logged.
+func (s *requestSampler) Sample(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !now.Before(s.resetAt) {
+		// Start a new tick, the map is replaced so keys that are no longer used do not keep using memory.
+		s.resetAt = now.Add(samplingTick)
+		clear(s.counts)
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+// DynamicSamplingKeyFunc returns the key used to group identical requests for WithDynamicSampling.
+type DynamicSamplingKeyFunc func(req *http.Request) string
+
+// defaultDynamicSamplingKeys is the number of keys the dynamicSampler keeps counts for if no maximum is configured.
+const defaultDynamicSamplingKeys = 1000
+
+// dynamicSampler logs the first of every rate requests with the same key. The counts are kept for the most recently
+// used keys only, a key that was evicted starts over with its first request logged.
+type dynamicSampler struct {
+	rate    uint64
+	maxKeys int
+
+	mu      sync.Mutex
+	entries map[string]*dynamicSampleEntry
+	// newest and oldest are the ends of the list of entries, ordered by the last time they were used.
+	newest *dynamicSampleEntry
+	oldest *dynamicSampleEntry
+}
+
+type dynamicSampleEntry struct {
+	key        string
+	count      uint64
+	prev, next *dynamicSampleEntry
+}
+
+func newDynamicSampler(rate, maxKeys int) *dynamicSampler {
+	if maxKeys <= 0 {
+		maxKeys = defaultDynamicSamplingKeys
+	}
+	return &dynamicSampler{
+		rate:    uint64(max(rate, 1)),
+		maxKeys: maxKeys,
+		entries: make(map[string]*dynamicSampleEntry, maxKeys),
+	}
+}
+
+// Sample returns true if the request with the key should be logged.
+func (s *dynamicSampler) Sample(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		if len(s.entries) >= s.maxKeys {
+			evicted := s.oldest
+			s.unlink(evicted)
+			delete(s.entries, evicted.key)
+		}
+		entry = &dynamicSampleEntry{key: key}
+		s.entries[key] = entry
+	} else {
+		s.unlink(entry)
+	}
+	s.pushNewest(entry)
+
+	entry.count++
+	return (entry.count-1)%s.rate == 0
+}
+
+func (s *dynamicSampler) unlink(entry *dynamicSampleEntry) {
+	if entry.prev != nil {
+		entry.prev.next = entry.next
+	} else {
+		s.oldest = entry.next
+	}
+	if entry.next != nil {
+		entry.next.prev = entry.prev
+	} else {
+		s.newest = entry.prev
+	}
+	entry.prev, entry.next = nil, nil
+}
+
+func (s *dynamicSampler) pushNewest(entry *dynamicSampleEntry) {
+	entry.prev = s.newest
+	if s.newest != nil {
+		s.newest.next = entry
+	} else {
+		s.oldest = entry
+	}
+	s.newest = entry
+}
+
+// dynamicSamplingKey returns the key of a completed request, requests with a different status code are never grouped.
+func dynamicSamplingKey(key string, statusCode int) string {
+	return key + " " + strconv.Itoa(statusCode)
+}
@@ -0,0 +1,166 @@
+package zaphttp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithStatusClassSampling(t *testing.T) {
+	t.Parallel()
+
+	const requests = 1000
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithMinimalPreset(),
+		zaphttp.WithStatusClassSampling(map[int]float64{2: 0.5, 4: 0}),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	for range requests {
+		for _, path := range []string{"/", "/error", "/missing"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+	}
+
+	// Server errors do not have a rate and should always be logged.
+	assert.Equal(t, requests, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+	// Client errors have a rate of 0 and should never be logged.
+	assert.Zero(t, logs.FilterLevelExact(zapcore.WarnLevel).Len())
+	// Successful requests should be logged about half of the time.
+	assert.InDelta(t, requests/2, logs.FilterLevelExact(zapcore.InfoLevel).Len(), requests/10)
+}
+
+func TestWithSampling(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(logger),
+		zaphttp.WithMinimalPreset(),
+		zaphttp.WithClock(func() time.Time { return now }),
+		zaphttp.WithSampling(2, 3),
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := requestLogger(mux)
+
+	for i := range 10 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", i), nil))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	// Requests 1, 2, 5 and 8 of the users route and the only request of the orders route are logged. A burst on one
+	// route does not suppress the logs of another.
+	assert.Equal(t, 5, logs.FilterLevelExact(zapcore.InfoLevel).Len())
+	// Errors are never sampled.
+	assert.Equal(t, 10, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+
+	// Counts are reset every second.
+	logs.TakeAll()
+	now = now.Add(time.Second)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestWithDynamicSampling(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should log the first and then 1 in rate identical requests", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithMinimalPreset(),
+			zaphttp.WithDynamicSampling(nil, 3, 0),
+		)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/users/{id}", func(w http.ResponseWriter, req *http.Request) {
+			if req.PathValue("id") == "cached" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+		mux.HandleFunc("/error", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		handler := requestLogger(mux)
+
+		for i := range 7 {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", i), nil))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+		}
+		// A different status code or method is not an identical request.
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/cached", nil))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users/1", nil))
+
+		// Requests 1, 4 and 7 of the users route, the 304 and the POST request are logged.
+		assert.Equal(t, 5, logs.FilterLevelExact(zapcore.InfoLevel).Len())
+		// Errors are never sampled.
+		assert.Equal(t, 7, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+	})
+
+	t.Run("Should log the first request again after its key was evicted", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithMinimalPreset(),
+			zaphttp.WithDynamicSampling(func(req *http.Request) string {
+				return req.URL.Path
+			}, 100, 2),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		for _, path := range []string{"/a", "/b", "/a", "/c", "/a", "/b"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		// "/b" is the least recently used key when "/c" is added, it is evicted and logged again.
+		assert.Equal(t, 4, logs.Len())
+	})
+}
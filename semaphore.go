@@ -0,0 +1,59 @@
+package zaphttp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// logSemaphore limits the number of goroutines that write log entries concurrently.
+type logSemaphore struct {
+	slots   chan struct{}
+	maxWait time.Duration
+	dropped atomic.Uint64
+}
+
+func newLogSemaphore(n int, maxWait time.Duration) *logSemaphore {
+	return &logSemaphore{
+		slots:   make(chan struct{}, n),
+		maxWait: maxWait,
+	}
+}
+
+// Acquire waits up to maxWait for a free slot. Returns false if no slot became available, the log entry should be
+// dropped in that case.
+func (s *logSemaphore) Acquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if s.maxWait <= 0 {
+		s.dropped.Add(1)
+		return false
+	}
+
+	timer := time.NewTimer(s.maxWait)
+	defer timer.Stop()
+
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+func (s *logSemaphore) Release() {
+	<-s.slots
+}
+
+// DroppedLogs returns the number of log entries that were dropped because the limit set by WithMaxConcurrentLogging was
+// reached. Returns 0 if WithMaxConcurrentLogging is not used.
+func (h *Handler) DroppedLogs() uint64 {
+	if h.logSemaphore == nil {
+		return 0
+	}
+	return h.logSemaphore.dropped.Load()
+}
@@ -0,0 +1,127 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// blockingCore is a zap core that calls onWrite before writing each entry. This simulates a slow log sink.
+type blockingCore struct {
+	zapcore.Core
+	onWrite func()
+}
+
+func (c *blockingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &blockingCore{Core: c.Core.With(fields), onWrite: c.onWrite}
+}
+
+func (c *blockingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *blockingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	c.onWrite()
+	return c.Core.Write(entry, fields)
+}
+
+func TestWithMaxConcurrentLogging(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should limit the number of concurrent writers", func(t *testing.T) {
+		t.Parallel()
+
+		var active, maxActive atomic.Int64
+		observerCore, logs := observer.New(zapcore.InfoLevel)
+		core := &blockingCore{
+			Core: observerCore,
+			onWrite: func() {
+				n := active.Add(1)
+				defer active.Add(-1)
+				for {
+					current := maxActive.Load()
+					if n <= current || maxActive.CompareAndSwap(current, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+			},
+		}
+
+		h := zaphttp.New(
+			zaphttp.WithLogger(zap.New(core)),
+			zaphttp.WithMaxConcurrentLogging(2, 5*time.Second),
+		)
+		handler := h.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 10, logs.Len())
+		assert.LessOrEqual(t, maxActive.Load(), int64(2))
+		assert.Zero(t, h.DroppedLogs())
+	})
+
+	t.Run("Should drop log entries when no slot becomes available in time", func(t *testing.T) {
+		t.Parallel()
+
+		entered := make(chan struct{})
+		release := make(chan struct{})
+		var once sync.Once
+
+		observerCore, logs := observer.New(zapcore.InfoLevel)
+		core := &blockingCore{
+			Core: observerCore,
+			onWrite: func() {
+				// Block the first writer until the second request is done.
+				once.Do(func() {
+					close(entered)
+					<-release
+				})
+			},
+		}
+
+		h := zaphttp.New(
+			zaphttp.WithLogger(zap.New(core)),
+			zaphttp.WithMaxConcurrentLogging(1, 0),
+		)
+		handler := h.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+
+		<-entered
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		close(release)
+		<-done
+
+		assert.Equal(t, 1, logs.Len())
+		assert.Equal(t, uint64(1), h.DroppedLogs())
+	})
+}
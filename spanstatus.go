@@ -0,0 +1,41 @@
+package zaphttp
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// SpanStatusFunc returns the status of a recording span, ok is false if the status can not be read. The trace API does
+// not expose the status of a span, spans created by the OpenTelemetry SDK can be converted to a ReadOnlySpan:
+//
+//	func(span trace.Span) (codes.Code, string, bool) {
+//		roSpan, ok := span.(sdktrace.ReadOnlySpan)
+//		if !ok {
+//			return codes.Unset, "", false
+//		}
+//		return roSpan.Status().Code, roSpan.Status().Description, true
+//	}
+type SpanStatusFunc func(span trace.Span) (code codes.Code, description string, ok bool)
+
+// spanStatusFields returns the status of the active span of the request, as returned by fn. Nothing is returned if the
+// span is not recording or the span status is not set.
+func spanStatusFields(req *http.Request, fn SpanStatusFunc) []zap.Field {
+	span := trace.SpanFromContext(req.Context())
+	if !span.IsRecording() {
+		return nil
+	}
+
+	code, description, ok := fn(span)
+	if !ok || code == codes.Unset {
+		return nil
+	}
+
+	fields := []zap.Field{zap.Stringer("span.status", code)}
+	if description != "" {
+		fields = append(fields, zap.String("span.status_message", description))
+	}
+	return fields
+}
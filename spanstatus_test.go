@@ -0,0 +1,101 @@
+package zaphttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// statusSpan is a span that keeps its status, like the spans of the OpenTelemetry SDK.
+type statusSpan struct {
+	noop.Span
+	recording   bool
+	code        codes.Code
+	description string
+}
+
+func (s *statusSpan) IsRecording() bool {
+	return s.recording
+}
+
+func (s *statusSpan) SetStatus(code codes.Code, description string) {
+	s.code = code
+	s.description = description
+}
+
+func statusFromSpan(span trace.Span) (codes.Code, string, bool) {
+	s, ok := span.(*statusSpan)
+	if !ok {
+		return codes.Unset, "", false
+	}
+	return s.code, s.description, true
+}
+
+func TestWithSpanStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		span     trace.Span
+		status   string
+		message  string
+		expected bool
+	}{
+		{
+			name:     "Should log the status of a recording span",
+			span:     &statusSpan{recording: true},
+			status:   "Error",
+			message:  "database unavailable",
+			expected: true,
+		},
+		{
+			name: "Should not log the status of non-recording spans",
+			span: &statusSpan{recording: false},
+		},
+		{
+			name: "Should not log the status if it can not be read",
+			span: noop.Span{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithSpanStatus(statusFromSpan),
+			)
+
+			ctx := trace.ContextWithSpan(context.Background(), tt.span)
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				trace.SpanFromContext(req.Context()).SetStatus(codes.Error, "database unavailable")
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			lines := logs.All()
+			assert.Len(t, lines, 1)
+			if !tt.expected {
+				assert.NotContains(t, lines[0].ContextMap(), "span.status")
+				return
+			}
+			assert.Equal(t, tt.status, lines[0].ContextMap()["span.status"])
+			assert.Equal(t, tt.message, lines[0].ContextMap()["span.status_message"])
+		})
+	}
+}
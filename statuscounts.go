@@ -0,0 +1,38 @@
+package zaphttp
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// statusCounter counts the number of completed requests per status code.
+type statusCounter struct {
+	counts sync.Map // map[int]*atomic.Int64
+}
+
+func (c *statusCounter) Increment(statusCode int) {
+	counter, ok := c.counts.Load(statusCode)
+	if !ok {
+		counter, _ = c.counts.LoadOrStore(statusCode, &atomic.Int64{})
+	}
+	counter.(*atomic.Int64).Add(1) //nolint:forcetypeassert // Only *atomic.Int64 values are stored.
+}
+
+func (c *statusCounter) Snapshot() map[int]int64 {
+	snapshot := make(map[int]int64)
+	c.counts.Range(func(key, value any) bool {
+		snapshot[key.(int)] = value.(*atomic.Int64).Load() //nolint:forcetypeassert // Only int keys and *atomic.Int64 values are stored.
+		return true
+	})
+	return snapshot
+}
+
+// StatusCounts returns the number of completed requests per status code. Requests that panicked are only counted if the
+// panic was recovered using WithRecover.
+// Returns nil if WithStatusCounts is not used.
+func (h *Handler) StatusCounts() map[int]int64 {
+	if h.statusCounter == nil {
+		return nil
+	}
+	return h.statusCounter.Snapshot()
+}
@@ -0,0 +1,84 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestStatusCounts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should count requests per status code", func(t *testing.T) {
+		t.Parallel()
+
+		h := zaphttp.New(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithStatusCounts(),
+		)
+		handler := h.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/missing":
+				w.WriteHeader(http.StatusNotFound)
+			case "/broken":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		}))
+
+		paths := map[string]int{"/": 10, "/missing": 5, "/broken": 2}
+
+		var wg sync.WaitGroup
+		for path, n := range paths {
+			for range n {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+				}()
+			}
+		}
+		wg.Wait()
+
+		assert.Equal(t, map[int]int64{
+			http.StatusOK:                  10,
+			http.StatusNotFound:            5,
+			http.StatusInternalServerError: 2,
+		}, h.StatusCounts())
+	})
+
+	t.Run("Should count recovered panics", func(t *testing.T) {
+		t.Parallel()
+
+		h := zaphttp.New(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithRecover(true),
+			zaphttp.WithStatusCounts(),
+			zaphttp.WithLatencyHistogram(),
+		)
+		handler := h.Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("boom")
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, map[int]int64{http.StatusInternalServerError: 1}, h.StatusCounts())
+		assert.Equal(t, uint64(1), h.LatencyHistogram()[""].Count)
+	})
+
+	t.Run("Should return nil when disabled", func(t *testing.T) {
+		t.Parallel()
+
+		h := zaphttp.New(zaphttp.WithLogger(zap.NewNop()))
+		h.Wrap(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Nil(t, h.StatusCounts())
+	})
+}
@@ -1,18 +1,72 @@
 package zaphttp
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
 	"net/http"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type statusRecorder struct {
 	writer            http.ResponseWriter
 	writeHeaderCalled bool
+	hijacked          bool
+	// onFirstWrite is called when the response header is written, before it is sent to the client.
+	onFirstWrite func()
+	// onHijack is called after the connection is hijacked.
+	onHijack func(conn net.Conn)
+	// firstWriteAt is the time the response header was written.
+	firstWriteAt time.Time
+	// now returns the current time, this is the clock of the handler.
+	now func() time.Time
+	// captureHeaders are the names of the response headers captured when the header is written.
+	captureHeaders []string
+	// headerFieldNames maps response header names to the field names they are logged as.
+	headerFieldNames map[string]string
+	// captureTrailers is true if the response trailers are captured in ResponseInfo.
+	captureTrailers bool
+	// errorBodyMaxBytes is the maximum number of bytes of the response body captured for error responses, 0 if
+	// disabled.
+	errorBodyMaxBytes int
+	// errorBodyMinStatus is the minimum status code of responses whose body is captured.
+	errorBodyMinStatus int
+	// captureBody is true if the body of this response is captured.
+	captureBody bool
+	// countLinesFor returns true if the lines of a response with the content type should be counted, nil if disabled.
+	countLinesFor contentTypeMatcher
+	// countLines is true if the lines of this response are counted.
+	countLines bool
 
-	StatusCode  int
-	ContentType string
+	// StatusCode is the status code sent to the client. Once a final (non-informational) status code is sent, later
+	// calls to WriteHeader are ignored by net/http and do not change it.
+	StatusCode int
+	// OverrideStatusCode is the status code of the last call to WriteHeader after the final status code was sent, 0 if
+	// there was no such call.
+	OverrideStatusCode int
+	ContentType        string
+	ContentLanguage    string
+	BytesWritten       int64
+	// Body contains the start of the response body, only captured for error responses.
+	Body []byte
+	// BodyTruncated is true if more of the response body was written than Body contains.
+	BodyTruncated bool
+	// LineCount is the number of newlines written, only counted if the content type matched countLinesFor.
+	LineCount    int64
+	Headers      map[string]string
+	HeaderFields []zap.Field
+	// ImplicitStatus is true if the handler returned without writing a response header, see finish.
+	ImplicitStatus bool
 }
 
-var _ http.ResponseWriter = &statusRecorder{}
+var (
+	_ http.ResponseWriter = &statusRecorder{}
+	_ http.Flusher        = &statusRecorder{}
+	_ http.Hijacker       = &statusRecorder{}
+)
 
 func (s *statusRecorder) Header() http.Header {
 	return s.writer.Header()
@@ -24,17 +78,133 @@ func (s *statusRecorder) Write(data []byte) (int, error) {
 		// When Write() is called before WriteHeader(), a 200 OK is returned.
 		s.WriteHeader(http.StatusOK)
 	}
-	return s.writer.Write(data)
+	n, err := s.writer.Write(data)
+	s.BytesWritten += int64(n)
+	if s.countLines {
+		s.LineCount += int64(bytes.Count(data[:n], []byte{'\n'}))
+	}
+	if s.captureBody {
+		s.capture(data[:n])
+	}
+	return n, err
 }
 
 func (s *statusRecorder) WriteHeader(statusCode int) {
+	if s.writeHeaderCalled && s.StatusCode >= http.StatusOK {
+		// The final status code was already sent, net/http ignores this call. Remember it to detect overrides.
+		s.OverrideStatusCode = statusCode
+		s.writer.WriteHeader(statusCode)
+		return
+	}
+
+	if !s.writeHeaderCalled {
+		s.firstWriteAt = s.now()
+		if s.onFirstWrite != nil {
+			s.onFirstWrite()
+		}
+	}
+	if len(s.captureHeaders) > 0 {
+		// Only capture the headers that are sent to the client. Headers of informational responses are replaced by the
+		// final response.
+		s.Headers = captureHeaders(s.writer.Header(), s.captureHeaders)
+	}
+	if len(s.headerFieldNames) > 0 {
+		s.HeaderFields = headerFields(s.writer.Header(), s.headerFieldNames)
+	}
+	if s.errorBodyMaxBytes > 0 {
+		s.captureBody = statusCode >= s.errorBodyMinStatus
+	}
+	if s.countLinesFor != nil {
+		s.countLines = s.countLinesFor(s.writer.Header().Get("Content-Type"))
+	}
 	s.writeHeaderCalled = true
 	s.StatusCode = statusCode
 	s.ContentType = s.writer.Header().Get("Content-Type")
+	s.ContentLanguage = s.writer.Header().Get("Content-Language")
 	s.writer.WriteHeader(statusCode)
 }
 
+// capture copies data to Body, up to errorBodyMaxBytes.
+func (s *statusRecorder) capture(data []byte) {
+	remaining := s.errorBodyMaxBytes - len(s.Body)
+	if len(data) > remaining {
+		data = data[:remaining]
+		s.BodyTruncated = true
+	}
+	s.Body = append(s.Body, data...)
+}
+
+// StatusOverridden returns true if WriteHeader was called again with a different status code after the final status
+// code was sent to the client, for example by an error page middleware.
+func (s *statusRecorder) StatusOverridden() bool {
+	return s.OverrideStatusCode != 0 && s.OverrideStatusCode != s.StatusCode
+}
+
+// Flush implements http.Flusher. Many streaming handlers use a type assertion instead of http.ResponseController, so
+// the wrapper has to implement it. Flushing is a no-op if the underlying writer does not support it.
+func (s *statusRecorder) Flush() {
+	_ = s.FlushError()
+}
+
+// FlushError flushes the response and returns the error of the underlying writer. http.ResponseController prefers this
+// over Flush, so errors like an exceeded write deadline reach the handler.
+func (s *statusRecorder) FlushError() error {
+	if !s.writeHeaderCalled {
+		// Flushing sends the header, with a 200 OK status if none was written yet.
+		s.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(s.writer).Flush()
+}
+
+// Hijack implements http.Hijacker, this is required for WebSocket libraries that use a type assertion to take over the
+// connection. Returns an error wrapping http.ErrNotSupported if the underlying writer can not be hijacked.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(s.writer).Hijack()
+	if err != nil {
+		return nil, nil, fmt.Errorf("zaphttp: can not hijack connection: %w", err)
+	}
+	s.hijacked = true
+	if s.onHijack != nil {
+		s.onHijack(conn)
+	}
+	return conn, rw, nil
+}
+
 // Unwrap implements the http.unWrapper interface (not exported). This is used for the http.ResponseController.
 func (s *statusRecorder) Unwrap() http.ResponseWriter {
 	return s.writer
 }
+
+// finish is called when the handler returned normally. If the handler did not write a response header, net/http sends a
+// 200 OK after the handler returns. Record that status instead of leaving StatusCode at 0. The header is not written
+// here, middleware wrapping the handler can still write a response. Hijacked connections have no status.
+func (s *statusRecorder) finish() {
+	if s.writeHeaderCalled || s.hijacked {
+		return
+	}
+	s.StatusCode = http.StatusOK
+	s.ContentType = s.writer.Header().Get("Content-Type")
+	s.ContentLanguage = s.writer.Header().Get("Content-Language")
+	s.ImplicitStatus = true
+}
+
+// ResponseInfo returns the information recorded about the response so far. Base contains the information that is
+// already known when the request is received.
+func (s *statusRecorder) ResponseInfo(base ResponseInfo) *ResponseInfo {
+	res := base
+	res.StatusCode = s.StatusCode
+	res.ContentType = s.ContentType
+	res.ContentLanguage = s.ContentLanguage
+	res.BytesWritten = s.BytesWritten
+	res.Hijacked = s.hijacked
+	res.ResponseHeaders = s.Headers
+	res.Latency = s.now().Sub(base.Start)
+	if s.captureTrailers {
+		// ResponseInfo is called after the handler returned, so all trailers are set.
+		res.Trailers = responseTrailers(s.writer.Header())
+	}
+	if !s.firstWriteAt.IsZero() {
+		res.TimeToFirstByte = s.firstWriteAt.Sub(base.Start)
+	}
+	return &res
+}
@@ -0,0 +1,279 @@
+package zaphttp_test
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should support flushing using a type assertion", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithMinimalPreset(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			flusher, ok := w.(http.Flusher)
+			require.True(t, ok, "response writer should implement http.Flusher")
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: first\n\n"))
+			flusher.Flush()
+			assert.True(t, rec.Flushed)
+
+			_, _ = w.Write([]byte("data: second\n\n"))
+			flusher.Flush()
+		})).ServeHTTP(rec, req)
+
+		assert.Equal(t, "data: first\n\ndata: second\n\n", rec.Body.String())
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.Equal(t, int64(http.StatusOK), lines[0].ContextMap()["status"])
+	})
+
+	t.Run("Should record a 200 status when flushing before writing", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithMinimalPreset(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.(http.Flusher).Flush() //nolint:forcetypeassert // Checked by the test above.
+		})).ServeHTTP(rec, req)
+
+		assert.True(t, rec.Flushed)
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.Equal(t, int64(http.StatusOK), lines[0].ContextMap()["status"])
+	})
+
+	t.Run("Should support hijacking the connection", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithMinimalPreset(),
+		)
+
+		srv := httptest.NewServer(requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hijacker, ok := w.(http.Hijacker)
+			if !assert.True(t, ok, "response writer should implement http.Hijacker") {
+				return
+			}
+
+			conn, rw, err := hijacker.Hijack()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+			_ = rw.Flush()
+		})))
+		defer srv.Close()
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+		require.NoError(t, err)
+
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+
+		require.Eventually(t, func() bool {
+			return logs.Len() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		line := logs.All()[0]
+		assert.Equal(t, true, line.ContextMap()["hijacked"])
+		assert.Equal(t, int64(0), line.ContextMap()["status"])
+	})
+
+	t.Run("Should log a hijacked line instead of the finished line", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithMinimalPreset(),
+			zaphttp.WithLogRequestEvenOnEarlyHijack(),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			conn, rw, err := w.(http.Hijacker).Hijack() //nolint:forcetypeassert // Checked by the test above.
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+			_ = rw.Flush()
+		}))
+
+		done := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer close(done)
+			handler.ServeHTTP(w, req)
+		}))
+		defer srv.Close()
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+		require.NoError(t, err)
+
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		<-done
+
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.Equal(t, "HTTP request hijacked", lines[0].Message)
+		assert.Equal(t, zapcore.InfoLevel, lines[0].Level)
+		assert.Contains(t, lines[0].ContextMap(), "hijack.elapsed")
+		assert.Equal(t, srv.Listener.Addr().String(), lines[0].ContextMap()["connection.local_address"])
+		assert.Equal(t, conn.LocalAddr().String(), lines[0].ContextMap()["connection.remote_address"])
+	})
+
+	t.Run("Should apply the path patterns and the field namespace to the hijacked line", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core)
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(logger),
+			zaphttp.WithMinimalPreset(),
+			zaphttp.WithLogRequestEvenOnEarlyHijack(),
+			zaphttp.WithOnlyLogPaths("/ws"),
+			zaphttp.WithFieldNamespace("zaphttp"),
+		)
+		handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			conn, rw, err := w.(http.Hijacker).Hijack() //nolint:forcetypeassert // Checked by the test above.
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+			_ = rw.Flush()
+		}))
+
+		done := make(chan struct{}, 2)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer func() { done <- struct{}{} }()
+			handler.ServeHTTP(w, req)
+		}))
+		defer srv.Close()
+
+		for _, path := range []string{"/other", "/ws"} {
+			conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+			require.NoError(t, err)
+
+			_, err = conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+			require.NoError(t, err)
+
+			res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			require.NoError(t, err)
+			_ = res.Body.Close()
+			_ = conn.Close()
+			<-done
+		}
+
+		// Only the request to the included path is logged.
+		lines := logs.All()
+		require.Len(t, lines, 1)
+		assert.Equal(t, "HTTP request hijacked", lines[0].Message)
+
+		fields, ok := lines[0].ContextMap()["zaphttp"].(map[string]interface{})
+		require.True(t, ok, "zaphttp field should be a map")
+		assert.Contains(t, fields, "hijack.elapsed")
+		assert.NotContains(t, lines[0].ContextMap(), "hijack.elapsed")
+	})
+
+	t.Run("Should pass http.ResponseController calls to the underlying writer", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+		)
+
+		srv := httptest.NewServer(requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			rc := http.NewResponseController(w)
+			assert.NoError(t, rc.SetReadDeadline(time.Now().Add(time.Minute)))
+			assert.NoError(t, rc.SetWriteDeadline(time.Now().Add(time.Minute)))
+			assert.NoError(t, rc.EnableFullDuplex())
+
+			_, _ = w.Write([]byte("first"))
+			assert.NoError(t, rc.Flush())
+
+			// An exceeded write deadline must reach the connection, the next flush fails.
+			assert.NoError(t, rc.SetWriteDeadline(time.Now().Add(-time.Second)))
+			_, _ = w.Write(make([]byte, 64*1024))
+			assert.Error(t, rc.Flush())
+		})))
+		defer srv.Close()
+
+		res, err := srv.Client().Get(srv.URL)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+	})
+
+	t.Run("Should return an error if the connection can not be hijacked", func(t *testing.T) {
+		t.Parallel()
+
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _, err := w.(http.Hijacker).Hijack() //nolint:forcetypeassert // Checked by the test above.
+			assert.ErrorIs(t, err, http.ErrNotSupported)
+		})).ServeHTTP(rec, req)
+	})
+}
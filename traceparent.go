@@ -0,0 +1,53 @@
+package zaphttp
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceparentFromHeader parses the W3C traceparent header of the request, see:
+// https://www.w3.org/TR/trace-context/#traceparent-header
+// Returns an invalid span context if the header is absent or malformed.
+func traceparentFromHeader(header http.Header) trace.SpanContext {
+	parts := strings.Split(strings.TrimSpace(header.Get("traceparent")), "-")
+	if len(parts) < 4 {
+		return trace.SpanContext{}
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		// Version ff is invalid, version 00 does not allow additional fields. Future versions may add fields.
+		return trace.SpanContext{}
+	}
+	if !isLowerHex(traceID, 32) || !isLowerHex(spanID, 16) || !isLowerHex(flags, 2) {
+		return trace.SpanContext{}
+	}
+
+	var cfg trace.SpanContextConfig
+	var flagBytes [1]byte
+	// Lengths and characters are validated above, decoding can not fail.
+	_, _ = hex.Decode(cfg.TraceID[:], []byte(traceID))
+	_, _ = hex.Decode(cfg.SpanID[:], []byte(spanID))
+	_, _ = hex.Decode(flagBytes[:], []byte(flags))
+	cfg.TraceFlags = trace.TraceFlags(flagBytes[0]) & trace.FlagsSampled
+	cfg.Remote = true
+
+	// The span context is invalid if the trace or span ID only contains zeroes.
+	return trace.NewSpanContext(cfg)
+}
+
+// isLowerHex returns true if s consists of n lowercase hexadecimal characters.
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
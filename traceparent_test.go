@@ -0,0 +1,121 @@
+package zaphttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		traceparent string
+		traceID     string
+		sampled     bool
+	}{
+		{
+			name:        "Sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+			sampled:     true,
+		},
+		{
+			name:        "Not sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			traceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:        "Future version with extra fields",
+			traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			traceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+			sampled:     true,
+		},
+		{name: "Absent"},
+		{name: "Too short", traceparent: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+		{name: "Uppercase", traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01"},
+		{name: "Zero trace ID", traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "Zero span ID", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "Invalid version", traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "Extra fields in version 00", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "Garbage", traceparent: "not a traceparent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(core)
+
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(logger),
+				zaphttp.WithParseTraceparent(true),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			rec := httptest.NewRecorder()
+
+			requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rec, req)
+
+			lines := logs.All()
+			require.Len(t, lines, 1)
+
+			if tt.traceID == "" {
+				assert.NotContains(t, lines[0].ContextMap(), "trace")
+				return
+			}
+			assert.Equal(t, map[string]interface{}{
+				"id":      tt.traceID,
+				"sampled": tt.sampled,
+			}, lines[0].ContextMap()["trace"])
+		})
+	}
+}
+
+func TestWithTraceIDResponseHeader(t *testing.T) {
+	t.Parallel()
+
+	requestLogger := zaphttp.NewHandler(
+		zaphttp.WithLogger(zap.NewNop()),
+		zaphttp.WithParseTraceparent(true),
+		zaphttp.WithTraceIDResponseHeader("X-Trace-ID"),
+	)
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("With span", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rec.Header().Get("X-Trace-ID"))
+	})
+
+	t.Run("Without span", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.NotContains(t, rec.Header(), "X-Trace-Id")
+	})
+}
@@ -0,0 +1,34 @@
+package zaphttp
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// upgradeFields returns fields describing a protocol upgrade requested by the client, like a WebSocket or h2c upgrade.
+// Returns nil if the request does not request an upgrade, see: https://www.rfc-editor.org/rfc/rfc9110#name-upgrade
+func upgradeFields(req *http.Request) []zap.Field {
+	upgrade := req.Header.Get("Upgrade")
+	if upgrade == "" || !headerContainsToken(req.Header, "Connection", "upgrade") {
+		return nil
+	}
+
+	return []zap.Field{
+		zap.Bool("upgrade_requested", true),
+		zap.String("request.upgrade", upgrade),
+	}
+}
+
+// headerContainsToken returns true if one of the comma separated values of the header equals token, ignoring case.
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
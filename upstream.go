@@ -0,0 +1,30 @@
+package zaphttp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseUpstreamLatency parses an upstream latency header value. Plain numbers are interpreted as seconds, like the
+// $upstream_response_time variable of nginx. Values with a unit are parsed using time.ParseDuration.
+func parseUpstreamLatency(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
@@ -0,0 +1,133 @@
+// Package zaphttpprom records Prometheus metrics for requests handled by the zaphttp middleware. It is a separate
+// module, so the core zaphttp module does not depend on the Prometheus client.
+package zaphttpprom
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PathLabelFunc returns the value of the "path" label for a request. Route is the route pattern returned by the
+// zaphttp.RoutePatternFunc (for example "/users/{id}"), or an empty string if no route matched. The function controls
+// the cardinality of the path label, never return the request path of unmatched requests without limiting it.
+type PathLabelFunc func(req *http.Request, route string) string
+
+// DefaultPathLabelFunc uses the matched route pattern as path label. Requests that did not match a route get an empty
+// path label, since their paths are unbounded.
+func DefaultPathLabelFunc(_ *http.Request, route string) string {
+	return route
+}
+
+type options struct {
+	namespace   string
+	buckets     []float64
+	pathLabelFn PathLabelFunc
+}
+
+// Option configures the metrics recorded by WithMetrics.
+type Option func(*options)
+
+// WithNamespace sets the namespace (prefix) of the metric names.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets sets the buckets of the latency histogram in seconds (default: prometheus.DefBuckets).
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+// WithPathLabel sets the function used to determine the path label (default: DefaultPathLabelFunc).
+func WithPathLabel(fn PathLabelFunc) Option {
+	return func(o *options) {
+		o.pathLabelFn = fn
+	}
+}
+
+type recorder struct {
+	pathLabelFn PathLabelFunc
+	requests    *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+}
+
+var _ zaphttp.MetricsRecorder = &recorder{}
+
+// WithMetrics records a request counter ("http_requests_total", labeled by method, status and path) and a latency
+// histogram ("http_request_duration_seconds", labeled by method and path) for every request handled by the zaphttp
+// handler. Requests where the handler panicked have status label "panic".
+//
+// The metrics are registered with reg. If they are already registered, for example because multiple handlers share a
+// registry, the existing metrics are used. Any other registration error causes a panic, like
+// prometheus.MustRegister.
+func WithMetrics(reg prometheus.Registerer, opts ...Option) zaphttp.HandlerOption {
+	o := &options{
+		buckets:     prometheus.DefBuckets,
+		pathLabelFn: DefaultPathLabelFunc,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	r := &recorder{
+		pathLabelFn: o.pathLabelFn,
+		requests: register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests handled.",
+		}, []string{"method", "status", "path"})),
+		latency: register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests in seconds.",
+			Buckets:   o.buckets,
+		}, []string{"method", "path"})),
+	}
+	return zaphttp.WithMetricsRecorder(r)
+}
+
+// register registers the collector with reg, returning the existing collector if it is already registered.
+func register[T prometheus.Collector](reg prometheus.Registerer, collector T) T {
+	if err := reg.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return collector
+}
+
+func (r *recorder) RecordRequest(req *http.Request, route string, res *zaphttp.ResponseInfo) {
+	method := methodLabel(req.Method)
+	path := r.pathLabelFn(req, route)
+
+	status := strconv.Itoa(res.StatusCode)
+	if res.Panicked {
+		status = "panic"
+	}
+
+	r.requests.WithLabelValues(method, status, path).Inc()
+	r.latency.WithLabelValues(method, path).Observe(res.Latency.Seconds())
+}
+
+// methodLabel returns the method label for a request. Clients can send any method, so unknown methods are grouped to
+// keep the cardinality of the label bounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
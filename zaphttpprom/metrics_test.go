@@ -0,0 +1,125 @@
+package zaphttpprom_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marnixbouhuis/zaphttp"
+	"github.com/marnixbouhuis/zaphttp/zaphttpprom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWithMetrics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should record requests labeled by route", func(t *testing.T) {
+		t.Parallel()
+
+		reg := prometheus.NewRegistry()
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttpprom.WithMetrics(reg),
+		)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		handler := requestLogger(mux)
+
+		for _, target := range []string{"/users/1", "/users/2", "/missing"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PURGE", "/users/1", nil))
+
+		expected := `
+# HELP http_requests_total Total number of HTTP requests handled.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",path="",status="404"} 1
+http_requests_total{method="GET",path="/users/{id}",status="200"} 2
+http_requests_total{method="OTHER",path="",status="405"} 1
+`
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total"))
+		assert.Equal(t, 3, testutil.CollectAndCount(reg, "http_request_duration_seconds"))
+	})
+
+	t.Run("Should use the path label function", func(t *testing.T) {
+		t.Parallel()
+
+		reg := prometheus.NewRegistry()
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttpprom.WithMetrics(reg,
+				zaphttpprom.WithNamespace("app"),
+				zaphttpprom.WithPathLabel(func(req *http.Request, route string) string {
+					if req.Pattern == "" {
+						return "unmatched"
+					}
+					return route
+				}),
+			),
+		)
+
+		handler := requestLogger(http.NotFoundHandler())
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/random/123", nil))
+
+		expected := `
+# HELP app_http_requests_total Total number of HTTP requests handled.
+# TYPE app_http_requests_total counter
+app_http_requests_total{method="GET",path="unmatched",status="404"} 1
+`
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "app_http_requests_total"))
+	})
+
+	t.Run("Should record panics", func(t *testing.T) {
+		t.Parallel()
+
+		reg := prometheus.NewRegistry()
+		requestLogger := zaphttp.NewHandler(
+			zaphttp.WithLogger(zap.NewNop()),
+			zaphttp.WithRecover(true),
+			zaphttpprom.WithMetrics(reg),
+		)
+
+		handler := requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			panic("broken")
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		expected := `
+# HELP http_requests_total Total number of HTTP requests handled.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",path="",status="panic"} 1
+`
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total"))
+	})
+
+	t.Run("Should share metrics between handlers using the same registry", func(t *testing.T) {
+		t.Parallel()
+
+		reg := prometheus.NewRegistry()
+		for range 2 {
+			requestLogger := zaphttp.NewHandler(
+				zaphttp.WithLogger(zap.NewNop()),
+				zaphttpprom.WithMetrics(reg),
+			)
+			requestLogger(http.NotFoundHandler()).ServeHTTP(
+				httptest.NewRecorder(),
+				httptest.NewRequest(http.MethodGet, "/", nil),
+			)
+		}
+
+		expected := `
+# HELP http_requests_total Total number of HTTP requests handled.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",path="",status="404"} 2
+`
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total"))
+	})
+}